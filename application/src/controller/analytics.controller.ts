@@ -1,11 +1,10 @@
 import { Request, Response } from 'express';
 import { StatusCodes } from 'http-status-codes';
 import { logger } from '../logger';
-import { AgeGroups, AgeGroupCounts, ElectionAnalyticsModel } from '../models/analytics.model';
-import { VoteModel } from '../models/election.model';
+import { ElectionAnalyticsModel } from '../models/analytics.model';
+import { BlockchainVoteTally } from '../models/election.model';
 import { withFabricConnection } from '../fabric-utils/fabric';
 import { BlockChainRepository } from '../fabric-utils/BlockChainRepository';
-import User from '../models/user.model';
 import { FeedbackModel } from '../models/feedback.model';
 
 // Cache for analytics
@@ -65,7 +64,7 @@ export async function getElectionAnalytics(req: Request, res: Response): Promise
     }
 
     // Calculate analytics on-demand
-    const analytics = await calculateElectionAnalytics(electionId, election);
+    const analytics = await calculateElectionAnalytics(userId, electionId, election);
 
     // Cache the response
     analyticsCache.set(electionId, {
@@ -83,143 +82,86 @@ export async function getElectionAnalytics(req: Request, res: Response): Promise
 }
 
 /**
- * Calculates analytics for an election on demand
+ * Calculates analytics for an election on demand from the ledger. Votes are counted
+ * from the on-chain turnout and candidates from the latest tally the caller may see;
+ * ballots are private, so no other per-vote figures can be derived.
+ * @param userId Caller whose identity queries the ledger
  * @param electionId Election ID
  * @param election Election object with candidate information
  */
-async function calculateElectionAnalytics(electionId: string, election: any): Promise<any> {
+async function calculateElectionAnalytics(userId: string, electionId: string, election: any): Promise<any> {
   logger.info(`Calculating analytics for election ${electionId}`);
 
-  // Get all votes for this election
-  const votes = await VoteModel.find({ election_id: electionId });
-  if (votes.length === 0) {
-    // Return empty analytics if no votes
-    return createEmptyAnalytics(electionId, election);
-  }
+  const { turnout, tally } = await withFabricConnection(userId, async (contract) => {
+    const blockchainRepo = new BlockChainRepository(contract);
+    const turnout = await blockchainRepo.getTurnout(electionId);
+
+    // No tally is shown while none has been stored or the results are embargoed for the caller
+    let tally: BlockchainVoteTally | null = null;
+    try {
+      tally = await blockchainRepo.getLatestTally(electionId);
+    } catch (error) {
+      logger.debug(`No tally available for election ${electionId}: ${error instanceof Error ? error.message : String(error)}`);
+    }
+
+    return { turnout, tally };
+  });
 
-  // Initialize structures
-  const candidateVoteCounts: Record<string, number> = {};
-  const ageGroupCounts: AgeGroupCounts = {
-    '0-17': 0,
-    '18-24': 0,
-    '25-34': 0,
-    '35-44': 0,
-    '45-54': 0,
-    '55+': 0
-  };
   const locationCounts: Record<string, number> = {};
+  for (const [governorate, governorateTurnout] of Object.entries<any>(turnout.by_governorate || {})) {
+    locationCounts[governorate] = governorateTurnout.votes;
+  }
+
+  const candidateVotes = tally ? candidateVotesFromTally(election, tally) : null;
+
+  // Feedback is stored per election, it does not need the votes
   const feedbackCounts = {
     positive: 0,
     neutral: 0,
     negative: 0
   };
-
-  // Initialize candidate votes counts
-  election.candidates.forEach((candidate: any) => {
-    candidateVoteCounts[candidate.candidate_id] = 0;
-  });
-
-  // Process each vote
-  for (const vote of votes) {
-    // Count candidate votes
-    if (candidateVoteCounts[vote.candidate_id] !== undefined) {
-      candidateVoteCounts[vote.candidate_id]++;
-    }
-
-    // Get voter information for demographics
-    const voter = await User.findOne({ userId: vote.voter_id });
-    if (voter) {
-      // Age group
-      let ageGroup: AgeGroups = '25-34'; // Default
-      if (voter.age) {
-        if (voter.age < 18) ageGroup = "0-17";
-        else if (voter.age <= 24) ageGroup = "18-24";
-        else if (voter.age <= 34) ageGroup = "25-34";
-        else if (voter.age <= 44) ageGroup = "35-44";
-        else if (voter.age <= 54) ageGroup = "45-54";
-        else ageGroup = "55+";
-      }
-      ageGroupCounts[ageGroup]++;
-
-      // Location
-      if (voter.governorate) {
-        locationCounts[voter.governorate] = (locationCounts[voter.governorate] || 0) + 1;
-      }
-    }
-
-    // Process feedback if available
-    const feedback = await FeedbackModel.findOne({ receipt: vote.receipt });
-    if (feedback) {
-      if (feedback.rating >= 4) feedbackCounts.positive++;
-      else if (feedback.rating >= 3) feedbackCounts.neutral++;
-      else feedbackCounts.negative++;
-    }
+  const feedbacks = await FeedbackModel.find({ election_id: electionId });
+  for (const feedback of feedbacks) {
+    if (feedback.rating >= 4) feedbackCounts.positive++;
+    else if (feedback.rating >= 3) feedbackCounts.neutral++;
+    else feedbackCounts.negative++;
   }
 
-  // Calculate total votes and percentages
-  const totalVotes = votes.length;
-  const candidateVotesWithNames = election.candidates.map((candidate: any) => {
-    const votes = candidateVoteCounts[candidate.candidate_id] || 0;
-    const percentage = totalVotes > 0 ? (votes / totalVotes) * 100 : 0;
-    return {
-      candidate_id: candidate.candidate_id,
-      name: candidate.name,
-      votes,
-      percentage: Number(percentage.toFixed(1))
-    };
-  });
-
   // Save the calculated analytics to the database for future reference
-  await saveAnalyticsToDB(electionId, totalVotes, candidateVotesWithNames, 
-    ageGroupCounts, locationCounts, feedbackCounts);
+  await saveAnalyticsToDB(electionId, turnout.votes, candidateVotes || [], locationCounts, feedbackCounts);
 
-  // Return the response
   return {
     election_id: electionId,
-    total_votes: totalVotes,
-    candidate_votes: candidateVotesWithNames,
-    voter_demographics: {
-      age_groups: ageGroupCounts
-    },
+    total_votes: turnout.votes,
+    registered_voters: turnout.registered_voters,
+    turnout_rate: turnout.rate,
+    candidate_votes: candidateVotes,
+    tally_id: tally ? tally.id : null,
+    is_final: tally ? tally.is_final : false,
     voter_locations: locationCounts,
+    hourly_turnout: turnout.hourly,
     voter_feedback: feedbackCounts,
     last_updated: new Date()
   };
 }
 
 /**
- * Creates empty analytics for an election with no votes
+ * Lists the votes and share of each candidate in a stored tally
  */
-function createEmptyAnalytics(electionId: string, election: any): any {
-  const candidateVotes = election.candidates.map((candidate: any) => ({
-    candidate_id: candidate.candidate_id,
-    name: candidate.name,
-    votes: 0,
-    percentage: 0
-  }));
+function candidateVotesFromTally(election: any, tally: BlockchainVoteTally): any[] {
+  const tallies: Record<string, number> = tally.tallies as any;
+  const totalVotes = Object.values(tallies).reduce((sum, votes) => sum + votes, 0);
 
-  return {
-    election_id: electionId,
-    total_votes: 0,
-    candidate_votes: candidateVotes,
-    voter_demographics: {
-      age_groups: {
-        '0-17': 0,
-        '18-24': 0,
-        '25-34': 0,
-        '35-44': 0,
-        '45-54': 0,
-        '55+': 0
-      }
-    },
-    voter_locations: {},
-    voter_feedback: {
-      positive: 0,
-      neutral: 0,
-      negative: 0
-    },
-    last_updated: new Date()
-  };
+  return election.candidates.map((candidate: any) => {
+    const votes = tallies[candidate.candidate_id] || 0;
+    const percentage = totalVotes > 0 ? (votes / totalVotes) * 100 : 0;
+    return {
+      candidate_id: candidate.candidate_id,
+      name: candidate.name,
+      votes,
+      percentage: Number(percentage.toFixed(1))
+    };
+  });
 }
 
 /**
@@ -229,7 +171,6 @@ async function saveAnalyticsToDB(
   electionId: string,
   totalVotes: number,
   candidateVotes: any[],
-  ageGroupCounts: AgeGroupCounts,
   locationCounts: Record<string, number>,
   feedbackCounts: { positive: number, neutral: number, negative: number }
 ): Promise<void> {
@@ -239,7 +180,6 @@ async function saveAnalyticsToDB(
       {
         total_votes: totalVotes,
         candidate_votes: candidateVotes,
-        voter_demographics: { age_groups: ageGroupCounts },
         voter_locations: locationCounts,
         voter_feedback: feedbackCounts,
         last_updated: new Date()
@@ -132,10 +132,10 @@ export async function recalculateTally(req: Request, res: Response): Promise<voi
             return;
         }
 
-        // Call blockchain to compute tally
+        // Evaluate the tally on the blockchain without writing to the ledger
         const blockchainTally = await withFabricConnection(req.user!.user_id, async (contract: Contract) => {
             const blockchainRepo = new BlockChainRepository(contract);
-            return await blockchainRepo.computeVoteTally(crypto.randomUUID(), election_id);
+            return await blockchainRepo.previewTally(election_id);
         });
 
         // Get tally from MongoDB (real-time tally)
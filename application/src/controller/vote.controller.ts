@@ -5,12 +5,14 @@ import { StatusCodes } from "http-status-codes";
 import { VoteModel, VoteTallyModel } from "../models/election.model";
 import { FeedbackModel } from "../models/feedback.model";
 import { logger } from "../logger";
-import crypto from 'crypto';
 import { ElectionAnalyticsModel } from "../models/analytics.model";
+import crypto from 'crypto';
 
 async function castVote(req: Request, res: Response, next: NextFunction): Promise<void> {
     try {
         const { election_id, candidate_id } = req.body;
+        // The nonce salts the receipt; voters may bring their own to keep it secret from us
+        const nonce: string = req.body.nonce || crypto.randomBytes(32).toString('hex');
         // Get userId from JWT token
         const userId = req.user?.user_id;
 
@@ -19,16 +21,17 @@ async function castVote(req: Request, res: Response, next: NextFunction): Promis
             return;
         }
 
-        const receipt = await withFabricConnection(userId, async (contract) => {
-            const voteId = crypto.randomUUID();
+        const { vote_id, receipt } = await withFabricConnection(userId, async (contract) => {
             const blockchainRepo = new BlockChainRepository(contract);
             // Call the CastVote function on the chaincode
-            return await blockchainRepo.castVote(voteId, election_id, candidate_id);
+            return await blockchainRepo.castVote(election_id, candidate_id, nonce);
         });
 
         res.status(StatusCodes.OK).json({
             message: 'Vote cast successfully',
-            receipt
+            vote_id,
+            receipt,
+            nonce
         });
     } catch (error) {
         res.status(StatusCodes.BAD_REQUEST).json({
@@ -12,6 +12,7 @@ import {
     ElectionRepository,
     UserRepository,
     VoteRepository,
+    VoteReceipt,
     AuditRepository
 } from './repositories';
 import { UserRole } from '../models/user.model';
@@ -79,6 +80,34 @@ export class BlockChainRepository {
         return this.electionRepo.computeVoteTally(tallyId, electionID);
     }
 
+    async computeFinalTally(electionID: string): Promise<BlockchainVoteTally> {
+        return this.electionRepo.computeFinalTally(electionID);
+    }
+
+    async setCertificationPolicy(electionID: string, required: number, signers: string[]): Promise<void> {
+        return this.electionRepo.setCertificationPolicy(electionID, required, signers);
+    }
+
+    async certifyTally(electionID: string, tallyHash: string): Promise<void> {
+        return this.electionRepo.certifyTally(electionID, tallyHash);
+    }
+
+    async getCertificationStatus(electionID: string): Promise<any> {
+        return this.electionRepo.getCertificationStatus(electionID);
+    }
+
+    async previewTally(electionID: string): Promise<BlockchainVoteTally> {
+        return this.electionRepo.previewTally(electionID);
+    }
+
+    async getLatestTally(electionID: string): Promise<BlockchainVoteTally> {
+        return this.electionRepo.getLatestTally(electionID);
+    }
+
+    async getTurnout(electionID: string): Promise<any> {
+        return this.electionRepo.getTurnout(electionID);
+    }
+
     async updateElectionStatus(electionID: string, newStatus: ElectionStatus): Promise<void> {
         return this.electionRepo.updateElectionStatus(electionID, newStatus);
     }
@@ -95,8 +124,8 @@ export class BlockChainRepository {
     /**
      * Vote Repository Methods
      */
-    async castVote(voteId: string, electionId: string, candidateId: string): Promise<string> {
-        return this.voteRepo.castVote(voteId, electionId, candidateId);
+    async castVote(electionId: string, candidateId: string, nonce: string): Promise<VoteReceipt> {
+        return this.voteRepo.castVote(electionId, candidateId, nonce);
     }
 
     async getVote(voteId: string): Promise<any> {
@@ -113,8 +142,4 @@ export class BlockChainRepository {
     async getUserRevocations(): Promise<any[]> {
         return this.auditRepo.getUserRevocations();
     }
-    
-    async getAuditVoteTally(electionId: string): Promise<void> {
-        return this.auditRepo.computeVoteTally(electionId);
-    }
 }
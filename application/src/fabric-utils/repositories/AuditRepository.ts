@@ -1,5 +1,4 @@
 import { Contract, EndorseError } from '@hyperledger/fabric-gateway';
-import { logger } from '../../logger';
 import { BaseRepository } from './BaseRepository';
 
 /**
@@ -20,16 +19,6 @@ export class AuditRepository extends BaseRepository {
     return resultJson ? JSON.parse(resultJson) : [];
   }
 
-  /**
-   * Compute vote tally for an election
-   * @param electionId The ID of the election
-   * @returns The computed tally or error
-   */
-  async computeVoteTally(electionId: string): Promise<void> {
-    await this.contract.submitTransaction('ComputeVoteTally', electionId);
-    logger.info(`Successfully computed tally for election ${electionId}`);
-  }
-
   /**
    * Get all votes for an election
    * @returns Array of votes
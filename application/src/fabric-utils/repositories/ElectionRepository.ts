@@ -111,7 +111,7 @@ export class ElectionRepository extends BaseRepository {
     }
 
     /**
-     * Compute the vote tally for an election
+     * Compute and store the vote tally of an election that is no longer live
      */
     async computeVoteTally(tallyID: string, electionID: string): Promise<BlockchainVoteTally> {
         logger.info('Submit Transaction: computeVoteTally for election with ID %s', electionID);
@@ -122,6 +122,70 @@ export class ElectionRepository extends BaseRepository {
         return tally;
     }
 
+    /**
+     * Propose the final tally of an ended election for certification
+     */
+    async computeFinalTally(electionID: string): Promise<BlockchainVoteTally> {
+        logger.info('Submit Transaction: ComputeFinalTally for election with ID %s', electionID);
+        const resultBytes = await this.contract.submitTransaction('ComputeFinalTally', electionID);
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson) as BlockchainVoteTally;
+    }
+
+    /**
+     * Set the M-of-N certification policy of a scheduled election, M being at least 2.
+     * The election cannot go live without one.
+     */
+    async setCertificationPolicy(electionID: string, required: number, signers: string[]): Promise<void> {
+        await this.contract.submitTransaction('SetCertificationPolicy', electionID, required.toString(), JSON.stringify(signers));
+        logger.info('Certification policy set for election %s: %d of %d', electionID, required, signers.length);
+    }
+
+    /**
+     * Certify the final tally of an election with the hash of the reviewed tally
+     */
+    async certifyTally(electionID: string, tallyHash: string): Promise<void> {
+        await this.contract.submitTransaction('CertifyTally', electionID, tallyHash);
+        logger.info('Final tally of election %s certified', electionID);
+    }
+
+    /**
+     * Get the certification policy and progress of an election
+     */
+    async getCertificationStatus(electionID: string): Promise<any> {
+        const resultBytes = await this.contract.evaluateTransaction('GetCertificationStatus', electionID);
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson);
+    }
+
+    /**
+     * Preview the current vote tally for an election without writing to the ledger
+     */
+    async previewTally(electionID: string): Promise<BlockchainVoteTally> {
+        logger.info('Evaluate Transaction: PreviewTally for election with ID %s', electionID);
+        const resultBytes = await this.contract.evaluateTransaction('PreviewTally', electionID);
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson) as BlockchainVoteTally;
+    }
+
+    /**
+     * Get the final tally of an election once there is one, otherwise its latest stored tally
+     */
+    async getLatestTally(electionID: string): Promise<BlockchainVoteTally> {
+        const resultBytes = await this.contract.evaluateTransaction('GetLatestTally', electionID);
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson) as BlockchainVoteTally;
+    }
+
+    /**
+     * Get the on-chain turnout of an election, per governorate and per hour
+     */
+    async getTurnout(electionID: string): Promise<any> {
+        const resultBytes = await this.contract.evaluateTransaction('GetTurnout', electionID);
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson);
+    }
+
     /**
      * Clear all elections (for testing)
      */
@@ -1,7 +1,13 @@
 import { Contract } from '@hyperledger/fabric-gateway';
+import crypto from 'crypto';
 import { logger } from '../../logger';
 import { BaseRepository } from './BaseRepository';
 
+export interface VoteReceipt {
+    vote_id: string;
+    receipt: string;
+}
+
 /**
  * Repository for interacting with vote-related operations on the blockchain
  */
@@ -11,17 +17,23 @@ export class VoteRepository extends BaseRepository {
     }
 
     /**
-     * Cast a vote in an election
-     * @param voteId The unique ID for the vote
+     * Cast a vote in an election. The chaincode derives the vote ID from the transaction ID.
+     * The candidate is sent in the transient map so it never appears in the transaction,
+     * with a random salt that keeps the ballot's hash in the block from revealing it.
      * @param electionId The ID of the election
      * @param candidateId The ID of the candidate being voted for
+     * @param nonce Secret kept by the voter that salts the receipt
      */
-    async castVote(voteId: string, electionId: string, candidateId: string): Promise<string> {
-        logger.info('Submit Transaction: CastVote, casting vote with ID %s for election %s and candidate %s', 
-            voteId, electionId, candidateId);
-        const resultBytes = await this.contract.submitTransaction('CastVote', voteId, electionId, candidateId);
-        const receipt = new TextDecoder().decode(resultBytes); // It is not actually json. Just a string
-        return receipt;
+    async castVote(electionId: string, candidateId: string, nonce: string): Promise<VoteReceipt> {
+        logger.info('Submit Transaction: CastVote, casting vote for election %s', electionId);
+        const salt = crypto.randomBytes(32).toString('hex');
+        const ballot = JSON.stringify({ candidate_id: candidateId, nonce, salt });
+        const resultBytes = await this.contract.submit('CastVote', {
+            arguments: [electionId],
+            transientData: { ballot },
+        });
+        const resultJson = new TextDecoder().decode(resultBytes);
+        return JSON.parse(resultJson) as VoteReceipt;
     }
 
     /**
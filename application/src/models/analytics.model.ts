@@ -1,16 +1,5 @@
 import mongoose, { Schema, Document } from 'mongoose';
 
-export interface AgeGroupCounts {
-    '0-17': number;
-    '18-24': number;
-    '25-34': number;
-    '35-44': number;
-    '45-54': number;
-    '55+': number;
-}
-
-export type AgeGroups = keyof AgeGroupCounts;
-
 interface GovernorateCount {
     [governorate: string]: number;
 }
@@ -29,9 +18,6 @@ export interface ElectionAnalytics extends Document {
         votes: number;
         percentage: number;
     }[];
-    voter_demographics: {
-        age_groups: AgeGroupCounts;
-    };
     voter_locations: GovernorateCount;
     voter_feedback: FeedbackCount;
     last_updated: Date;
@@ -54,15 +40,6 @@ const ElectionAnalyticsSchema = new Schema({
         votes: { type: Number, default: 0 },
         percentage: { type: Number, default: 0 }
     }],
-    voter_demographics: {
-        age_groups: {
-            '18-24': { type: Number, default: 0 },
-            '25-34': { type: Number, default: 0 },
-            '35-44': { type: Number, default: 0 },
-            '45-54': { type: Number, default: 0 },
-            '55+': { type: Number, default: 0 }
-        }
-    },
     voter_locations: {
         type: Map,
         of: Number,
@@ -8,6 +8,7 @@ export type EventType =
   | 'election_created' 
   | 'election_updated' 
   | 'tally_computed' 
+  | 'results_certified'
   | 'user_registered' 
   | 'user_status_updated'
   | 'election_status_changed';
@@ -62,6 +62,8 @@ export interface Election {
   status: ElectionStatus;
   eligible_governorates: Governorate[];
   election_image: string; // URL to election image
+  tie_break_rule?: TieBreakRule;
+  lots_seed?: string; // Committed by the chaincode when the election is created
 }
 
 // Request/Response models - simplified
@@ -73,6 +75,7 @@ export interface CreateElectionRequest {
   end_time: string;
   eligible_governorates: Governorate[];
   election_image: string; // URL
+  tie_break_rule?: TieBreakRule; // defaults to runoff
 }
 
 export interface CreateElectionResponse {
@@ -92,9 +95,9 @@ export interface VoteDocument extends Document, Vote {
 
 export interface Vote {
   vote_id: string;
-  voter_id: string;
+  voter_id?: string; // Ballots on the ledger no longer carry the voter
   election_id: string;
-  candidate_id: string;
+  candidate_id?: string; // Kept in the ballot private data collection on the ledger
   receipt: string;
   created_at: Date;
 }
@@ -114,11 +117,26 @@ export interface VoteTally {
 }
 
 // Original blockchain VoteTally (renamed to avoid conflicts)
+// How a tie for first place in the final tally is resolved
+export type TieBreakRule = 'runoff' | 'lots' | 'manual';
+
+export interface ElectionResult {
+  ranking: { candidate_id: string; votes: number; rank: number }[];
+  winners: string[];
+  tied: string[];
+  tie_break_rule: TieBreakRule;
+  outcome: 'winner' | 'lots_drawn' | 'runoff_required' | 'awaiting_commission_decision' | 'decided_by_commission';
+  reasoning: string;
+  seed?: string;
+}
+
 export interface BlockchainVoteTally {
   id: string;
   user_id: string; // user who invoked to compute the tally
   election_id: string;
   tallies: Map<string, number>;
+  by_governorate: Record<string, Record<string, number>>; // governorate -> candidate -> count
+  result?: ElectionResult; // only on the final tally
   created_at: Date;
   is_final: boolean;
 }
@@ -126,9 +144,9 @@ export interface BlockchainVoteTally {
 // Schema only for votes - users will be stored in MongoDB
 const VoteSchema = new Schema({
   vote_id: { type: String, required: true, unique: true },
-  voter_id: { type: String, required: true },
+  voter_id: { type: String },
   election_id: { type: String, required: true },
-  candidate_id: { type: String, required: true },
+  candidate_id: { type: String },
   receipt: { type: String, required: true },
   timestamp: { type: Date, default: Date.now },
   created_at: { type: Date, default: Date.now }
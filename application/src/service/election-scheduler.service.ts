@@ -3,7 +3,6 @@ import { Contract } from '@hyperledger/fabric-gateway';
 import { ElectionStatus, VoteModel, VoteTally, VoteTallyModel } from "../models/election.model";
 import { logger } from "../logger";
 import { fabricAdminConnection } from "../fabric-utils/fabric";
-import crypto from 'crypto';
 
 /**
  * Service to manage the lifecycle of elections, automatically transitioning
@@ -77,11 +76,10 @@ export class ElectionSchedulerService {
                     logger.info(`Ending election ${election.election_id}: ${election.name}`);
                     await this.blockchainRepo.updateElectionStatus(election.election_id, ElectionStatus.Ended);
                     
-                    const tallyId = crypto.randomUUID(); // Generate a unique ID for the tally
-                    // Compute the final tally for the ended election
-                    logger.info(`Computing final tally for election ${election.election_id}`);
-                    const blockchainTally = await this.blockchainRepo.computeVoteTally(tallyId, election.election_id);
-                    logger.info(`Final tally computed for election ${election.election_id}`);
+                    // Propose the final tally, it must be certified before the results are published
+                    logger.info(`Proposing final tally for election ${election.election_id}`);
+                    const blockchainTally = await this.blockchainRepo.computeFinalTally(election.election_id);
+                    logger.info(`Final tally proposed for election ${election.election_id}`);
 
                     const finalTally: VoteTally = {
                         election_id: election.election_id,
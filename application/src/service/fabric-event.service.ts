@@ -151,6 +151,11 @@ export class FabricEventService {
                     await this.handleTallyComputed(tallyData, blockNumber, transactionId);
                     break;
 
+                  case 'results_certified':
+                    const certifiedData = JSON.parse(Buffer.from(event.payload).toString());
+                    await this.handleResultsCertified(certifiedData, blockNumber, transactionId);
+                    break;
+
                   case 'user_registered':
                     const userData = JSON.parse(Buffer.from(event.payload).toString());
                     await this.handleUserRegistered(userData, blockNumber, transactionId);
@@ -399,10 +404,8 @@ export class FabricEventService {
       // Create audit event for election update
       const auditEvent = createAuditEvent('election_updated', {
         election_id: electionId,
-        updater_id: 'system', // In a real system, this would come from the event
-        updated_fields: Object.keys(electionData).filter(key =>
-          key !== 'election_id' && key !== 'electionId'
-        ),
+        updater_id: electionData.updated_by || 'system',
+        updated_fields: Object.keys(electionData.changes || {}),
         status: electionData.status || 'unknown'
       }, blockNumber, txId);
 
@@ -422,15 +425,18 @@ export class FabricEventService {
    * @param txId The transaction ID from the event
    */
   private async handleVoteCast(voteData: Vote, blockNumber?: bigint, txId?: string): Promise<void> {
-    logger.info(`Vote cast for candidate ${voteData.candidate_id} in election ${voteData.election_id}`);
+    logger.info(`Vote cast in election ${voteData.election_id}`);
 
     try {
       // Save vote record to MongoDB with blockchain-generated receipt
       // No need to fetch the vote again as we have all the data from the event
       await VoteModel.create(voteData);
 
-      // Update the vote tally in real-time
-      await this.updateVoteTally(voteData.election_id, voteData.candidate_id);
+      // The candidate stays in the ballot private data collection, so the event
+      // can only update the tally when an older chaincode still includes it
+      if (voteData.candidate_id) {
+        await this.updateVoteTally(voteData.election_id, voteData.candidate_id);
+      }
 
       // Note: Analytics are now calculated on-demand via the API endpoint
       // instead of being updated in real-time here
@@ -438,7 +444,6 @@ export class FabricEventService {
       // Create audit event for the vote
       const auditEvent = createAuditEvent('vote_cast', {
         election_id: voteData.election_id,
-        voter_id: voteData.voter_id,
         candidate_id: voteData.candidate_id,
         receipt: voteData.receipt,
         vote_id: voteData.vote_id
@@ -480,6 +485,29 @@ export class FabricEventService {
     }
   }
 
+  /**
+   * Handle results certified event
+   * @param certifiedData The certification data from the event
+   * @param blockNumber The block number from the event
+   * @param txId The transaction ID from the event
+   */
+  private async handleResultsCertified(certifiedData: any, blockNumber?: bigint, txId?: string): Promise<void> {
+    logger.info(`Results certified for election ${certifiedData.election_id} by ${certifiedData.signers.join(', ')}`);
+
+    try {
+      const auditEvent = createAuditEvent('results_certified', {
+        election_id: certifiedData.election_id,
+        tally_hash: certifiedData.tally_hash,
+        signers: certifiedData.signers,
+        timestamp: certifiedData.timestamp
+      }, blockNumber, txId);
+
+      await AuditEventModel.create(auditEvent);
+    } catch (error) {
+      logger.error(`Failed to record results certified audit event: ${error instanceof Error ? error.message : String(error)}`);
+    }
+  }
+
   /**
    * Handle user registered event
    * @param userData The user data from the event
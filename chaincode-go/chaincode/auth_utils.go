@@ -1,9 +1,9 @@
 package chaincode
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
@@ -11,80 +11,259 @@ import (
 
 // IsUserAdmin checks if the calling user is an admin
 func (s *VotingContract) IsUserAdmin(ctx contractapi.TransactionContextInterface) (bool, error) {
-	id, err := getUserId(ctx)
+	if err := checkPermission(ctx, "IsUserAdmin", ""); err != nil {
+		return false, err
+	}
+
+	caller, err := authorize(ctx, "")
+	if err != nil {
+		return false, err
+	}
+
+	return caller.hasRole(roleAdmin), nil
+}
+
+// getUserId returns the MSP-qualified ID of the calling client, <MSPID>/<CN>, from
+// the VotingContext when beforeTransaction has already loaded it
+func getUserId(ctx contractapi.TransactionContextInterface) (string, error) {
+	if votingCtx, ok := ctx.(*VotingContext); ok && votingCtx.loaded {
+		return votingCtx.callerID, nil
+	}
+
+	return clientUserID(ctx)
+}
+
+// clientUserID reads the MSP-qualified ID of the calling client from its identity. The
+// CN is read from the parsed certificate subject; qualifying it with the MSP ID keeps
+// two organizations that issue the same CN from sharing a user record.
+func clientUserID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
-		return false, fmt.Errorf("failed to get client identity: %v", err)
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
 	}
 
-	userJSON, err := ctx.GetStub().GetState(userPrefix + id)
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
 	if err != nil {
-		return false, fmt.Errorf("failed to read user from world state: %v", err)
+		return "", fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert == nil {
+		return "", fmt.Errorf("client identity has no X.509 certificate")
 	}
-	if userJSON == nil {
-		return false, fmt.Errorf("user does not exist")
+	if cert.Subject.CommonName == "" {
+		return "", fmt.Errorf("CN not found in certificate subject: %s", cert.Subject)
 	}
 
-	var user User
-	err = json.Unmarshal(userJSON, &user)
+	return qualifiedUserID(mspID, cert.Subject.CommonName), nil
+}
+
+// qualifiedUserID joins an MSP ID and a CN into a user ID
+func qualifiedUserID(mspID string, cn string) string {
+	return mspID + "/" + cn
+}
+
+// qualifyUserID returns a user ID passed as a transaction argument in its
+// MSP-qualified form. A bare CN is taken to belong to the caller's own MSP.
+func qualifyUserID(ctx contractapi.TransactionContextInterface, userID string) (string, error) {
+	if strings.Contains(userID, "/") {
+		return userID, nil
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
 	if err != nil {
-		return false, err
+		return "", fmt.Errorf("failed to get MSP ID: %v", err)
 	}
 
-	return user.Role == "admin", nil
+	return qualifiedUserID(mspID, userID), nil
 }
 
-// ensureAdmin validates that the calling client is an admin
-func (s *VotingContract) ensureAdmin(ctx contractapi.TransactionContextInterface) error {
-	isAdmin, err := s.IsUserAdmin(ctx)
+// getCaller returns the calling client's ID and their registered user record
+func getCaller(ctx contractapi.TransactionContextInterface) (string, *User, error) {
+	callerID, caller, err := lookupCaller(ctx)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	if !isAdmin {
-		return fmt.Errorf("operation requires admin privileges")
+	if caller == nil {
+		return "", nil, fmt.Errorf("caller %s does not exist", callerID)
 	}
-	return nil
+
+	return callerID, caller, nil
 }
 
-// Helper function to extract CN value from client identity
-func getUserId(ctx contractapi.TransactionContextInterface) (string, error) {
-	// Get the full identity string
-	identity, err := ctx.GetClientIdentity().GetID()
+// lookupCaller returns the calling client's ID and user record, which is nil when
+// the client is not registered. The record is a copy the caller may modify.
+func lookupCaller(ctx contractapi.TransactionContextInterface) (string, *User, error) {
+	if votingCtx, ok := ctx.(*VotingContext); ok && votingCtx.loaded {
+		if votingCtx.caller == nil {
+			return votingCtx.callerID, nil, nil
+		}
+		caller := *votingCtx.caller
+		return votingCtx.callerID, &caller, nil
+	}
+
+	callerID, err := getUserId(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get identity: %v", err)
+		return "", nil, fmt.Errorf("failed to get client identity: %v", err)
 	}
 
-	fullID, err := base64.StdEncoding.DecodeString(identity)
+	callerJSON, err := ctx.GetStub().GetState(userPrefix + callerID)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode identity: %v", err)
+		return "", nil, fmt.Errorf("failed to read caller from world state: %v", err)
+	}
+	if callerJSON == nil {
+		return callerID, nil, nil
 	}
 
-	// The format is typically x509::{subject}::{issuer}
-	// We need to extract just the CN value from the subject part
-	parts := strings.Split(string(fullID), "::")
-	if len(parts) < 2 {
-		return "", fmt.Errorf("invalid identity format: %s", fullID)
+	var caller User
+	err = json.Unmarshal(callerJSON, &caller)
+	if err != nil {
+		return "", nil, err
 	}
 
-	// Get the subject part
-	subjectPart := parts[1]
+	return callerID, &caller, nil
+}
 
-	// Parse out the CN
-	cnPrefix := "CN="
-	cnStartIndex := strings.Index(subjectPart, cnPrefix)
-	if cnStartIndex == -1 {
-		return "", fmt.Errorf("CN not found in identity: %s", fullID)
+// principal is a registered caller with the roles it acts under
+type principal struct {
+	id    string
+	user  *User
+	roles []string
+}
+
+// hasRole reports whether the caller acts under any of the given roles
+func (p *principal) hasRole(roles ...string) bool {
+	for _, role := range p.roles {
+		if slices.Contains(roles, role) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Move past the "CN=" prefix
-	cnStartIndex += len(cnPrefix)
+// role names the caller's roles for error messages
+func (p *principal) role() string {
+	return strings.Join(p.roles, "+")
+}
 
-	// Find the end of the CN value (either comma or end of string)
-	cnEndIndex := strings.Index(subjectPart[cnStartIndex:], ",")
-	if cnEndIndex == -1 {
-		// No comma found, CN is the rest of the subject
-		return subjectPart[cnStartIndex:], nil
-	} else {
-		// Comma found, extract just the CN value
-		return subjectPart[cnStartIndex : cnStartIndex+cnEndIndex], nil
+// authorize loads the caller and resolves its roles under the role policy. Every
+// role check goes through it, so a suspended caller holds no role. Unless allowed is
+// empty, it fails when the caller holds none of the allowed roles; action completes
+// the error message.
+func authorize(ctx contractapi.TransactionContextInterface, action string, allowed ...string) (*principal, error) {
+	callerID, user, err := getCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != "active" {
+		return nil, fmt.Errorf("account %s is %s", callerID, user.Status)
 	}
+
+	roles, err := callerRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	caller := &principal{id: callerID, user: user, roles: roles}
+	if len(allowed) > 0 && !caller.hasRole(allowed...) {
+		return nil, fmt.Errorf("role %s is not allowed to %s", caller.role(), action)
+	}
+
+	return caller, nil
+}
+
+// certificateRole reads the role attribute of the client certificate. Only the CA of
+// governanceMSP is trusted to issue it: any other member organization could enroll its
+// own users with whatever role it likes, so their attributes are ignored.
+func certificateRole(ctx contractapi.TransactionContextInterface) (string, bool, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != governanceMSP {
+		return "", false, nil
+	}
+
+	certRole, found, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read certificate attributes: %v", err)
+	}
+
+	return certRole, found, nil
+}
+
+// callerRoles combines the on-chain role of the caller with the role attribute of
+// its certificate as the role policy says
+func callerRoles(ctx contractapi.TransactionContextInterface, user *User) ([]string, error) {
+	policy, err := getRolePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if policy.Policy == rolePolicyOnChain {
+		return []string{user.Role}, nil
+	}
+
+	certRole, found, err := certificateRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch policy.Policy {
+	case rolePolicyAttribute:
+		if !found {
+			return nil, fmt.Errorf("certificate of %s has no %s attribute issued by %s", user.ID, roleAttribute, governanceMSP)
+		}
+		return []string{certRole}, nil
+	case rolePolicyBoth:
+		if !found || certRole != user.Role {
+			return nil, fmt.Errorf("certificate role %q of %s does not match its on-chain role %s", certRole, user.ID, user.Role)
+		}
+		return []string{user.Role}, nil
+	case rolePolicyEither:
+		if !found || certRole == user.Role {
+			return []string{user.Role}, nil
+		}
+		return []string{user.Role, certRole}, nil
+	default:
+		return nil, fmt.Errorf("unknown role policy %s", policy.Policy)
+	}
+}
+
+// governanceMSP is the organization whose MSP admins may bootstrap and repair access
+// control. Any member organization can issue admin certificates, so an MSP admin of
+// another organization is trusted with nothing. Changing it takes a new chaincode
+// package, approved under the channel's lifecycle endorsement policy.
+const governanceMSP = "Org1MSP"
+
+// isGovernanceAdmin reports whether the client is an MSP admin of governanceMSP
+func isGovernanceAdmin(ctx contractapi.TransactionContextInterface) (bool, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return false, fmt.Errorf("failed to get MSP ID: %v", err)
+	}
+	if mspID != governanceMSP {
+		return false, nil
+	}
+
+	return isMSPAdmin(ctx)
+}
+
+// isMSPAdmin reports whether the client's certificate marks it as an admin of its
+// MSP, either with the admin OU of NodeOUs or the hf.Type=admin attribute of Fabric CA
+func isMSPAdmin(ctx contractapi.TransactionContextInterface) (bool, error) {
+	identityType, found, err := ctx.GetClientIdentity().GetAttributeValue("hf.Type")
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate attributes: %v", err)
+	}
+	if found && identityType == "admin" {
+		return true, nil
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return false, fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if cert != nil && slices.Contains(cert.Subject.OrganizationalUnit, "admin") {
+		return true, nil
+	}
+
+	return false, nil
 }
@@ -0,0 +1,150 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Each ballot is stored once, under ballot~electionID~voteID in the ballot collection.
+// Tallies range over an election's ballots instead of incrementing a shared counter, so
+// concurrent votes never conflict on MVCC. Fabric puts the hash of every private key in
+// the block, so the key only holds values that are public anyway.
+
+// ballotKey returns the collection key of the ballot of a vote
+func ballotKey(ctx contractapi.TransactionContextInterface, electionID string, voteID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(ballotObjectType, []string{electionID, voteID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// putBallot stores the ballot of a vote in the ballot collection
+func putBallot(ctx contractapi.TransactionContextInterface, electionID string, voteID string, ballotJSON []byte) error {
+	key, err := ballotKey(ctx, electionID, voteID)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(ballotCollection, key, ballotJSON)
+	if err != nil {
+		return fmt.Errorf("failed to store private ballot: %v", err)
+	}
+
+	return nil
+}
+
+// getPrivateBallot reads the ballot of a vote from the ballot collection
+func getPrivateBallot(ctx contractapi.TransactionContextInterface, electionID string, voteID string) (*PrivateBallot, error) {
+	key, err := ballotKey(ctx, electionID, voteID)
+	if err != nil {
+		return nil, err
+	}
+
+	ballotJSON, err := ctx.GetStub().GetPrivateData(ballotCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private ballot %s: %v", voteID, err)
+	}
+	if ballotJSON == nil {
+		return nil, fmt.Errorf("the ballot of vote %s is not in the ballot collection", voteID)
+	}
+
+	var ballot PrivateBallot
+	err = json.Unmarshal(ballotJSON, &ballot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ballot, nil
+}
+
+// sumBallots aggregates the ballots of an election into candidateID -> vote count
+// and governorate -> candidateID -> vote count. Every candidate still standing is present,
+// with 0 if nobody voted for them, in the totals and in every eligible governorate.
+func sumBallots(ctx contractapi.TransactionContextInterface, election *Election) (map[string]int, map[string]map[string]int, error) {
+	tally := newCandidateCounts(election)
+	byGovernorate := make(map[string]map[string]int)
+	for _, governorate := range election.EligibleGovernorates {
+		byGovernorate[governorate] = newCandidateCounts(election)
+	}
+
+	err := scanBallots(ctx, election.ElectionID, func(ballot *PrivateBallot) error {
+		if _, isValidCandidate := tally[ballot.CandidateID]; !isValidCandidate {
+			return fmt.Errorf("invalid candidate ID found in ballot: %s", ballot.CandidateID)
+		}
+		tally[ballot.CandidateID]++
+
+		if byGovernorate[ballot.Governorate] == nil {
+			byGovernorate[ballot.Governorate] = newCandidateCounts(election)
+		}
+		byGovernorate[ballot.Governorate][ballot.CandidateID]++
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tally, byGovernorate, nil
+}
+
+// sumGovernorateBallots aggregates the ballots of one governorate into candidateID -> vote count
+func sumGovernorateBallots(ctx contractapi.TransactionContextInterface, election *Election, governorate string) (map[string]int, error) {
+	tally := newCandidateCounts(election)
+
+	err := scanBallots(ctx, election.ElectionID, func(ballot *PrivateBallot) error {
+		if ballot.Governorate != governorate {
+			return nil
+		}
+		if _, isValidCandidate := tally[ballot.CandidateID]; !isValidCandidate {
+			return fmt.Errorf("invalid candidate ID found in ballot: %s", ballot.CandidateID)
+		}
+		tally[ballot.CandidateID]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tally, nil
+}
+
+// newCandidateCounts returns a zero count for every candidate still standing
+func newCandidateCounts(election *Election) map[string]int {
+	counts := make(map[string]int)
+	for _, candidate := range election.Candidates {
+		if !candidate.Withdrawn {
+			counts[candidate.CandidateID] = 0
+		}
+	}
+	return counts
+}
+
+// scanBallots calls count for every ballot of an election
+func scanBallots(ctx contractapi.TransactionContextInterface, electionID string, count func(ballot *PrivateBallot) error) error {
+	iterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(ballotCollection, ballotObjectType, []string{electionID})
+	if err != nil {
+		return fmt.Errorf("failed to get ballots: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to get next ballot: %v", err)
+		}
+
+		var ballot PrivateBallot
+		err = json.Unmarshal(queryResult.Value, &ballot)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal ballot: %v", err)
+		}
+
+		if err := count(&ballot); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
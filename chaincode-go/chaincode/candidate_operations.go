@@ -0,0 +1,101 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// AddCandidate adds a candidate to a scheduled election (commission only)
+func (s *VotingContract) AddCandidate(ctx contractapi.TransactionContextInterface, electionID string, candidateJSON string) error {
+	var candidate Candidate
+	err := json.Unmarshal([]byte(candidateJSON), &candidate)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal candidate: %v", err)
+	}
+	if candidate.CandidateID == "" || candidate.Name == "" {
+		return fmt.Errorf("missing required fields in candidate input")
+	}
+	if candidate.Withdrawn {
+		return fmt.Errorf("cannot add a withdrawn candidate")
+	}
+
+	callerID, election, err := s.getEditableElection(ctx, "AddCandidate", electionID)
+	if err != nil {
+		return err
+	}
+
+	// Withdrawn candidates stay in the list, so this also stops their IDs being reused
+	if slices.ContainsFunc(election.Candidates, func(c Candidate) bool { return c.CandidateID == candidate.CandidateID }) {
+		return fmt.Errorf("candidate ID %s is already used in election %s", candidate.CandidateID, electionID)
+	}
+
+	oldCandidates := slices.Clone(election.Candidates)
+	election.Candidates = append(election.Candidates, candidate)
+
+	changes := map[string]FieldChange{"candidates": {Old: oldCandidates, New: election.Candidates}}
+	return saveElectionUpdate(ctx, election, changes, callerID)
+}
+
+// WithdrawCandidate marks a candidate of a scheduled election as withdrawn (commission only).
+// The candidate stays in the election so the CandidateID remains reserved.
+func (s *VotingContract) WithdrawCandidate(ctx contractapi.TransactionContextInterface, electionID string, candidateID string) error {
+	callerID, election, err := s.getEditableElection(ctx, "WithdrawCandidate", electionID)
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(election.Candidates, func(c Candidate) bool { return c.CandidateID == candidateID })
+	if index == -1 {
+		return fmt.Errorf("candidate %s does not exist in election %s", candidateID, electionID)
+	}
+	if election.Candidates[index].Withdrawn {
+		return fmt.Errorf("candidate %s has already withdrawn", candidateID)
+	}
+
+	oldCandidates := slices.Clone(election.Candidates)
+	election.Candidates[index].Withdrawn = true
+	if err := validateCandidates(election.Candidates); err != nil {
+		return err
+	}
+
+	changes := map[string]FieldChange{"candidates": {Old: oldCandidates, New: election.Candidates}}
+	return saveElectionUpdate(ctx, election, changes, callerID)
+}
+
+// ReorderCandidates sets the display order of a scheduled election's candidates (commission only).
+// candidateIDsJSON must be a JSON array holding every candidate ID of the election exactly once.
+func (s *VotingContract) ReorderCandidates(ctx contractapi.TransactionContextInterface, electionID string, candidateIDsJSON string) error {
+	var candidateIDs []string
+	err := json.Unmarshal([]byte(candidateIDsJSON), &candidateIDs)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal candidate IDs: %v", err)
+	}
+
+	callerID, election, err := s.getEditableElection(ctx, "ReorderCandidates", electionID)
+	if err != nil {
+		return err
+	}
+	if len(candidateIDs) != len(election.Candidates) {
+		return fmt.Errorf("expected %d candidate IDs, got %d", len(election.Candidates), len(candidateIDs))
+	}
+
+	reordered := make([]Candidate, 0, len(candidateIDs))
+	for _, candidateID := range candidateIDs {
+		index := slices.IndexFunc(election.Candidates, func(c Candidate) bool { return c.CandidateID == candidateID })
+		if index == -1 {
+			return fmt.Errorf("candidate %s does not exist in election %s", candidateID, electionID)
+		}
+		if slices.ContainsFunc(reordered, func(c Candidate) bool { return c.CandidateID == candidateID }) {
+			return fmt.Errorf("duplicate candidate ID: %s", candidateID)
+		}
+		reordered = append(reordered, election.Candidates[index])
+	}
+
+	changes := map[string]FieldChange{"candidates": {Old: election.Candidates, New: reordered}}
+	election.Candidates = reordered
+
+	return saveElectionUpdate(ctx, election, changes, callerID)
+}
@@ -0,0 +1,351 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Results are published only after an M-of-N set of commission and auditor
+// identities has certified the final tally. Each CertifyTally transaction is signed
+// by its submitter, so the certifications on the ledger are the signatures.
+
+// SetCertificationPolicy sets how many of which signers must certify the final tally
+// of an election. It is locked once the election leaves scheduled.
+func (s *VotingContract) SetCertificationPolicy(ctx contractapi.TransactionContextInterface, electionID string, required int, signersJSON string) error {
+	if err := checkPermission(ctx, "SetCertificationPolicy", electionID); err != nil {
+		return err
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election.Status != statusScheduled {
+		return fmt.Errorf("election %s is %s, its certification policy can no longer change", electionID, election.Status)
+	}
+
+	var signers []string
+	err = json.Unmarshal([]byte(signersJSON), &signers)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal signers: %v", err)
+	}
+	if len(signers) < minCertifications {
+		return fmt.Errorf("certification policy needs at least %d signers", minCertifications)
+	}
+	if required < minCertifications || required > len(signers) {
+		return fmt.Errorf("required certifications must be between %d and %d, got %d", minCertifications, len(signers), required)
+	}
+
+	seen := make(map[string]bool)
+	for i, signerID := range signers {
+		signerID, err := qualifyUserID(ctx, signerID)
+		if err != nil {
+			return err
+		}
+		signers[i] = signerID
+
+		if seen[signerID] {
+			return fmt.Errorf("duplicate signer %s", signerID)
+		}
+		seen[signerID] = true
+
+		signer, err := getUser(ctx, signerID)
+		if err != nil {
+			return err
+		}
+		if signer.Role != roleCommission && signer.Role != roleAuditor {
+			return fmt.Errorf("signer %s must be election commission or auditor, not %s", signerID, signer.Role)
+		}
+	}
+
+	setAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	policy := CertificationPolicy{
+		ElectionID: electionID,
+		Required:   required,
+		Signers:    signers,
+		SetBy:      callerID,
+		SetAt:      setAt,
+	}
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal certification policy: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(certificationPolicyPrefix+electionID, policyJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save certification policy: %v", err)
+	}
+
+	eventPayload, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("certification_policy_set", eventPayload)
+	if err != nil {
+		return fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return nil
+}
+
+// CertifyTally records the caller's approval of the proposed final tally. The caller
+// passes the hash of the tally they reviewed so a tally changed in between is not certified.
+func (s *VotingContract) CertifyTally(ctx contractapi.TransactionContextInterface, electionID string, tallyHash string) error {
+	if err := checkPermission(ctx, "CertifyTally", electionID); err != nil {
+		return err
+	}
+
+	callerID, _, err := getCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election.Status != statusEnded {
+		return fmt.Errorf("election %s is %s, only ended elections can be certified", electionID, election.Status)
+	}
+
+	policy, err := getCertificationPolicy(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(policy.Signers, callerID) {
+		return fmt.Errorf("%s is not a signer of the certification policy of election %s", callerID, electionID)
+	}
+
+	finalHash, err := getFinalTallyHash(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if tallyHash != finalHash {
+		return fmt.Errorf("tally hash %s does not match the final tally of election %s", tallyHash, electionID)
+	}
+
+	// Signers certify the winner too, so a manual tie must be decided first
+	result, err := s.getElectionResult(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if result.Outcome == outcomeAwaitingDecision {
+		return fmt.Errorf("the tie in election %s awaits a commission decision", electionID)
+	}
+
+	certificationKey, err := ctx.GetStub().CreateCompositeKey(certificationObjectType, []string{electionID, callerID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(certificationKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("%s has already certified election %s", callerID, electionID)
+	}
+
+	// Range queries do not see this transaction's writes, so count before storing
+	certifications, err := getCertifications(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	certifiedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	certification := Certification{
+		ElectionID:  electionID,
+		TallyID:     finalTallyID,
+		TallyHash:   finalHash,
+		SignerID:    callerID,
+		CertifiedAt: certifiedAt,
+	}
+	certificationJSON, err := json.Marshal(certification)
+	if err != nil {
+		return fmt.Errorf("failed to marshal certification: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(certificationKey, certificationJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save certification: %v", err)
+	}
+
+	signers := []string{callerID}
+	for _, previous := range certifications {
+		signers = append(signers, previous.SignerID)
+	}
+	sort.Strings(signers)
+
+	eventName := "tally_certified"
+	eventPayload := map[string]interface{}{
+		"election_id": electionID,
+		"tally_id":    finalTallyID,
+		"tally_hash":  finalHash,
+		"signer_id":   callerID,
+		"signers":     signers,
+		"required":    policy.Required,
+		"timestamp":   certifiedAt,
+	}
+	if len(signers) == policy.Required {
+		eventName = "results_certified"
+	}
+
+	eventPayloadJSON, err := json.Marshal(eventPayload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent(eventName, eventPayloadJSON)
+	if err != nil {
+		return fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return nil
+}
+
+// GetCertificationStatus returns the certification policy and progress of an election
+func (s *VotingContract) GetCertificationStatus(ctx contractapi.TransactionContextInterface, electionID string) (*CertificationStatus, error) {
+	if err := checkPermission(ctx, "GetCertificationStatus", electionID); err != nil {
+		return nil, err
+	}
+
+	if _, err := getElection(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	policy, err := getCertificationPolicy(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	certifications, err := getCertifications(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := CertificationStatus{
+		ElectionID:     electionID,
+		Policy:         policy,
+		Certifications: certifications,
+		Certified:      len(certifications) >= policy.Required,
+	}
+
+	tallyHash, err := getFinalTallyHash(ctx, electionID)
+	if err == nil {
+		status.TallyHash = tallyHash
+	}
+
+	return &status, nil
+}
+
+// ensureCertified fails unless the final tally of an election reached its certification quorum
+func ensureCertified(ctx contractapi.TransactionContextInterface, electionID string) error {
+	policy, err := getCertificationPolicy(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	certifications, err := getCertifications(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if len(certifications) < policy.Required {
+		return fmt.Errorf("election %s needs %d certifications to be published, has %d", electionID, policy.Required, len(certifications))
+	}
+
+	// Policies stored before the minimum quorum may still let the proposer certify alone
+	final, err := getTallyRecord(ctx, electionID, finalTallyID)
+	if err != nil {
+		return err
+	}
+	for _, certification := range certifications {
+		if certification.SignerID != final.UserID {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the final tally of election %s must be certified by someone other than its proposer %s", electionID, final.UserID)
+}
+
+// getCertificationPolicy reads the certification policy of an election
+func getCertificationPolicy(ctx contractapi.TransactionContextInterface, electionID string) (*CertificationPolicy, error) {
+	policyJSON, err := ctx.GetStub().GetState(certificationPolicyPrefix + electionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if policyJSON == nil {
+		return nil, fmt.Errorf("election %s has no certification policy", electionID)
+	}
+
+	var policy CertificationPolicy
+	err = json.Unmarshal(policyJSON, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// getCertifications reads the certifications submitted for an election
+func getCertifications(ctx contractapi.TransactionContextInterface, electionID string) ([]*Certification, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(certificationObjectType, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get certifications: %v", err)
+	}
+	defer iterator.Close()
+
+	certifications := []*Certification{}
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next certification: %v", err)
+		}
+
+		var certification Certification
+		err = json.Unmarshal(queryResult.Value, &certification)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal certification: %v", err)
+		}
+		certifications = append(certifications, &certification)
+	}
+
+	return certifications, nil
+}
+
+// getFinalTallyHash returns the hex SHA-256 of the stored final tally of an election
+func getFinalTallyHash(ctx contractapi.TransactionContextInterface, electionID string) (string, error) {
+	tallyKey, err := ctx.GetStub().CreateCompositeKey(tallyObjectType, []string{electionID, finalTallyID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	tallyJSON, err := ctx.GetStub().GetState(tallyKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tallyJSON == nil {
+		return "", fmt.Errorf("the final tally of election %s has not been proposed", electionID)
+	}
+
+	hash := sha256.Sum256(tallyJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
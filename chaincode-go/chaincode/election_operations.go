@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
@@ -11,6 +12,15 @@ import (
 
 // GetElection returns the election stored in the world state with given electionID
 func (s *VotingContract) GetElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	if err := checkPermission(ctx, "GetElection", electionID); err != nil {
+		return nil, err
+	}
+
+	return getElection(ctx, electionID)
+}
+
+// getElection reads an election from the world state
+func getElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
 	electionJSON, err := ctx.GetStub().GetState(electionPrefix + electionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
@@ -28,8 +38,31 @@ func (s *VotingContract) GetElection(ctx contractapi.TransactionContextInterface
 	return &election, nil
 }
 
+// parseVotingWindow returns the parsed StartTime and EndTime of an election
+func parseVotingWindow(election *Election) (time.Time, time.Time, error) {
+	startTime, err := time.Parse(time.RFC3339, election.StartTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start time %q: %v", election.StartTime, err)
+	}
+
+	endTime, err := time.Parse(time.RFC3339, election.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end time %q: %v", election.EndTime, err)
+	}
+
+	if !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end time %s must be after start time %s", election.EndTime, election.StartTime)
+	}
+
+	return startTime, endTime, nil
+}
+
 // GetAllElections returns all elections found in world state
 func (s *VotingContract) GetAllElections(ctx contractapi.TransactionContextInterface) ([]*Election, error) {
+	if err := checkPermission(ctx, "GetAllElections", ""); err != nil {
+		return nil, err
+	}
+
 	resultsIterator, err := ctx.GetStub().GetStateByRange(electionPrefix, electionPrefix+"}")
 	if err != nil {
 		return nil, err
@@ -54,8 +87,12 @@ func (s *VotingContract) GetAllElections(ctx contractapi.TransactionContextInter
 	return elections, nil
 }
 
-// CreateElection creates a new election from JSON input (admin only)
+// CreateElection creates a new election from JSON input (commission or admin)
 func (s *VotingContract) CreateElection(ctx contractapi.TransactionContextInterface, electionInputJSON string) error {
+	if err := checkPermission(ctx, "CreateElection", ""); err != nil {
+		return err
+	}
+
 	// Parse the JSON input
 	var input Election
 	err := json.Unmarshal([]byte(electionInputJSON), &input)
@@ -69,6 +106,19 @@ func (s *VotingContract) CreateElection(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("missing required fields in election input")
 	}
 
+	if err := validateCandidates(input.Candidates); err != nil {
+		return err
+	}
+
+	if err := validateTieBreakRule(input.TieBreakRule); err != nil {
+		return err
+	}
+
+	// Validate the voting window, CastVote enforces it against the tx timestamp
+	if _, _, err := parseVotingWindow(&input); err != nil {
+		return err
+	}
+
 	// Check if election already exists
 	electionJSON, err := ctx.GetStub().GetState(electionPrefix + input.ElectionID)
 	if err != nil {
@@ -78,6 +128,10 @@ func (s *VotingContract) CreateElection(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("election already exists with Id: %s", input.ElectionID)
 	}
 
+	// The lots seed is committed now, whatever the input says, long before anyone
+	// knows the final tally it will be combined with
+	input.LotsSeed = newLotsSeed(ctx)
+
 	electionJSON, err = json.Marshal(input)
 	if err != nil {
 		return fmt.Errorf("failed to marshal election input: %v", err)
@@ -98,118 +152,172 @@ func (s *VotingContract) CreateElection(ctx contractapi.TransactionContextInterf
 	return nil
 }
 
-// ComputeVoteTally calculates tally on demand
-func (s *VotingContract) ComputeVoteTally(ctx contractapi.TransactionContextInterface, tallyID string, electionID string) (*VoteTally, error) {
-	// First get the election to validate it exists and initialize tally
-	election, err := s.GetElection(ctx, electionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get election %s: %v", electionID, err)
+// UpdateElection applies a JSON patch to an election that has not started yet (commission only)
+func (s *VotingContract) UpdateElection(ctx contractapi.TransactionContextInterface, electionID string, patchJSON string) error {
+	// Reject unknown fields so status or IDs cannot be smuggled in through the patch
+	var patch ElectionPatch
+	decoder := json.NewDecoder(strings.NewReader(patchJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		return fmt.Errorf("failed to unmarshal election patch: %v", err)
 	}
 
-	// Initialize tally with 0 for all candidates
-	tally := make(map[string]int)
-	for _, candidate := range election.Candidates {
-		tally[candidate.CandidateID] = 0
-	}
-
-	// Get all votes using range query with prefix
-	iterator, err := ctx.GetStub().GetStateByRange(votePrefix, votePrefix+"}")
+	callerID, election, err := s.getEditableElection(ctx, "UpdateElection", electionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get votes: %v", err)
+		return err
 	}
-	defer iterator.Close()
 
-	// Count votes
-	for iterator.HasNext() {
-		queryResult, err := iterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get next vote: %v", err)
+	changes := make(map[string]FieldChange)
+	if patch.Name != nil && *patch.Name != election.Name {
+		if *patch.Name == "" {
+			return fmt.Errorf("election name cannot be empty")
 		}
-
-		var vote Vote
-		err = json.Unmarshal(queryResult.Value, &vote)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal vote: %v", err)
+		changes["name"] = FieldChange{Old: election.Name, New: *patch.Name}
+		election.Name = *patch.Name
+	}
+	if patch.Description != nil && *patch.Description != election.Description {
+		changes["description"] = FieldChange{Old: election.Description, New: *patch.Description}
+		election.Description = *patch.Description
+	}
+	if patch.ElectionImage != nil && *patch.ElectionImage != election.ElectionImage {
+		changes["election_image"] = FieldChange{Old: election.ElectionImage, New: *patch.ElectionImage}
+		election.ElectionImage = *patch.ElectionImage
+	}
+	if patch.Candidates != nil && !slices.Equal(*patch.Candidates, election.Candidates) {
+		if err := validateCandidates(*patch.Candidates); err != nil {
+			return err
 		}
-
-		// Only count votes for the specified election
-		if vote.ElectionID == electionID {
-			// Validate candidate is valid for this election
-			candidateIDs := make([]string, len(election.Candidates))
-			for i, candidate := range election.Candidates {
-				candidateIDs[i] = candidate.CandidateID
-			}
-			isValidCandidate := slices.Contains(candidateIDs, vote.CandidateID)
-			if !isValidCandidate {
-				return nil, fmt.Errorf("invalid candidate ID found in vote: %s", vote.CandidateID)
+		// Withdrawn candidates keep their ID reserved, so they cannot be dropped
+		for _, candidate := range election.Candidates {
+			if candidate.Withdrawn && !slices.ContainsFunc(*patch.Candidates, func(c Candidate) bool {
+				return c.CandidateID == candidate.CandidateID && c.Withdrawn
+			}) {
+				return fmt.Errorf("candidate %s was withdrawn and must stay withdrawn", candidate.CandidateID)
 			}
-			tally[vote.CandidateID]++
 		}
+		changes["candidates"] = FieldChange{Old: election.Candidates, New: *patch.Candidates}
+		election.Candidates = *patch.Candidates
+	}
+	if patch.EligibleGovernorates != nil && !slices.Equal(*patch.EligibleGovernorates, election.EligibleGovernorates) {
+		changes["eligible_governorates"] = FieldChange{Old: election.EligibleGovernorates, New: *patch.EligibleGovernorates}
+		election.EligibleGovernorates = *patch.EligibleGovernorates
+	}
+	if patch.StartTime != nil && *patch.StartTime != election.StartTime {
+		changes["start_time"] = FieldChange{Old: election.StartTime, New: *patch.StartTime}
+		election.StartTime = *patch.StartTime
+	}
+	if patch.EndTime != nil && *patch.EndTime != election.EndTime {
+		changes["end_time"] = FieldChange{Old: election.EndTime, New: *patch.EndTime}
+		election.EndTime = *patch.EndTime
+	}
+	if patch.TieBreakRule != nil && *patch.TieBreakRule != election.TieBreakRule {
+		if err := validateTieBreakRule(*patch.TieBreakRule); err != nil {
+			return err
+		}
+		changes["tie_break_rule"] = FieldChange{Old: election.TieBreakRule, New: *patch.TieBreakRule}
+		election.TieBreakRule = *patch.TieBreakRule
 	}
 
-	// Get the client identity ID
-	clientID, err := getUserId(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	if len(changes) == 0 {
+		return fmt.Errorf("patch does not change election %s", electionID)
+	}
+	if _, _, err := parseVotingWindow(election); err != nil {
+		return err
 	}
 
-	// Create a VoteTally structure to save the results
-	voteTally := VoteTally{
-		ID:         tallyID,
-		UserID:     clientID,
-		ElectionID: electionID,
-		Tallies:    tally,
-		CreatedAt:  time.Now().Format(time.RFC3339),
-		IsFinal:    false,
+	return saveElectionUpdate(ctx, election, changes, callerID)
+}
+
+// getEditableElection returns the caller's ID and an election it may edit through the
+// given transaction: the permission matrix must allow it and the election must still
+// be scheduled
+func (s *VotingContract) getEditableElection(ctx contractapi.TransactionContextInterface, function string, electionID string) (string, *Election, error) {
+	if err := checkPermission(ctx, function, electionID); err != nil {
+		return "", nil, err
 	}
 
-	if election.Status == "completed" {
-		voteTally.IsFinal = true
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get client identity: %v", err)
 	}
 
-	// Save tally to state
-	tallyJSON, err := json.Marshal(voteTally)
+	election, err := getElection(ctx, electionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal vote tally: %v", err)
+		return "", nil, err
+	}
+	if election.Status != statusScheduled {
+		return "", nil, fmt.Errorf("election %s is %s and can no longer be edited", electionID, election.Status)
 	}
 
-	err = ctx.GetStub().PutState(tallyPrefix+electionID, tallyJSON)
+	return callerID, election, nil
+}
+
+// saveElectionUpdate stores an edited election and emits election_updated with the diff
+func saveElectionUpdate(ctx contractapi.TransactionContextInterface, election *Election, changes map[string]FieldChange, updatedBy string) error {
+	electionJSON, err := json.Marshal(election)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save vote tally: %v", err)
+		return fmt.Errorf("failed to marshal election: %v", err)
 	}
 
-	// Emit an event with the election ID for the tally computation
-	tallyEventPayload, err := json.Marshal(map[string]string{
-		"electionId": electionID,
-		"timestamp":  voteTally.CreatedAt,
-	})
+	err = ctx.GetStub().PutState(electionPrefix+election.ElectionID, electionJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal tally event payload: %v", err)
+		return fmt.Errorf("failed to update election: %v", err)
 	}
 
-	err = ctx.GetStub().SetEvent("tally_computed", tallyEventPayload)
+	timestamp, err := getTxTimestamp(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to emit tally_computed event: %v", err)
+		return err
 	}
 
-	// Save updated election
-	electionJSON, err := json.Marshal(election)
+	// Emit only the diff, listeners already hold the rest of the election
+	eventPayload, err := json.Marshal(map[string]interface{}{
+		"election_id": election.ElectionID,
+		"changes":     changes,
+		"updated_by":  updatedBy,
+		"timestamp":   timestamp,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal updated election: %v", err)
+		return fmt.Errorf("failed to marshal event payload: %v", err)
 	}
 
-	err = ctx.GetStub().PutState(tallyPrefix+electionID, electionJSON)
+	err = ctx.GetStub().SetEvent("election_updated", eventPayload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save updated election: %v", err)
+		return fmt.Errorf("failed to emit election_updated event: %v", err)
 	}
 
-	return &voteTally, nil
+	return nil
+}
+
+// validateCandidates checks a candidate list has unique IDs and at least one active candidate
+func validateCandidates(candidates []Candidate) error {
+	if len(candidates) == 0 {
+		return fmt.Errorf("election must have at least one candidate")
+	}
+
+	seen := make(map[string]bool)
+	active := 0
+	for _, candidate := range candidates {
+		if candidate.CandidateID == "" {
+			return fmt.Errorf("candidate ID cannot be empty")
+		}
+		if seen[candidate.CandidateID] {
+			return fmt.Errorf("duplicate candidate ID: %s", candidate.CandidateID)
+		}
+		seen[candidate.CandidateID] = true
+		if !candidate.Withdrawn {
+			active++
+		}
+	}
+	if active == 0 {
+		return fmt.Errorf("election must have at least one candidate who has not withdrawn")
+	}
+
+	return nil
 }
 
 // ClearElections removes all elections from the ledger - restricted to admins only
 func (s *VotingContract) ClearElections(ctx contractapi.TransactionContextInterface) error {
-	// Ensure admin privileges
-	if err := s.ensureAdmin(ctx); err != nil {
+	if err := checkPermission(ctx, "ClearElections", ""); err != nil {
 		return err
 	}
 
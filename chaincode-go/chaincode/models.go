@@ -5,17 +5,139 @@ const (
 	votePrefix     = "vote_"
 	electionPrefix = "election_"
 	userPrefix     = "user_"
-	tallyPrefix    = "tally_"
+
+	receiptBoardPrefix        = "receipt_board_"
+	certificationPolicyPrefix = "certification_policy_"
+	tieResolutionPrefix       = "tie_resolution_"
+	permissionPrefix          = "permission_"
+
+	adminBootstrapKey = "admin_bootstrap" // Set once InitAdmin has created the first admin
+	rolePolicyKey     = "role_policy"     // Where caller roles come from, see RolePolicy
+)
+
+// Private data collections and transient map keys
+const (
+	ballotCollection   = "ballotCollection"
+	ballotTransientKey = "ballot"
+	minNonceLength     = 16
+	minSaltLength      = 32
+)
+
+// finalTallyID is the tally ID reserved for the immutable final tally of an election
+const finalTallyID = "final"
+
+// minCertifications is the smallest quorum a certification policy may require
+const minCertifications = 2
+
+// Object types for composite keys
+const (
+	participationObjectType = "participation"     // participation~electionID~voterID
+	receiptObjectType       = "receipt"           // receipt~electionID~receipt -> voteID
+	ballotObjectType        = "ballot"            // ballot~electionID~voteID -> PrivateBallot, in the ballot collection
+	voteElectionIndex       = "vote~election"     // vote~election~electionID~voteID
+	tallyObjectType         = "tally"             // tally~electionID~tallyID
+	turnoutObjectType       = "turnout"           // turnout~electionID~governorate~hour~counterID
+	voterGovernorateIndex   = "voter~governorate" // voter~governorate~governorate~userID
+	certificationObjectType = "certification"     // certification~electionID~signerID
+	recountObjectType       = "recount"           // recount~electionID~recountID
+)
+
+// Election lifecycle statuses
+const (
+	statusScheduled = "scheduled"
+	statusLive      = "live"
+	statusEnded     = "ended"
+	statusPublished = "published"
+	statusCancelled = "cancelled"
+)
+
+// Tie-break rules applied when candidates tie for first place in the final tally
+const (
+	tieBreakRunoff = "runoff" // the tied candidates go to a runoff election, the default
+	tieBreakLots   = "lots"   // lots are drawn from the seed committed at creation and the final tally
+	tieBreakManual = "manual" // the election commission decides through ResolveTie
+)
+
+// Outcomes of the final tally
+const (
+	outcomeWinner           = "winner"
+	outcomeLotsDrawn        = "lots_drawn"
+	outcomeRunoffRequired   = "runoff_required"
+	outcomeAwaitingDecision = "awaiting_commission_decision"
+	outcomeCommissionChoice = "decided_by_commission"
+)
+
+// User roles
+const (
+	roleVoter      = "voter"
+	roleCommission = "election_commission"
+	roleAuditor    = "auditor"
+	roleAdmin      = "admin"
+)
+
+// roleAttribute is the Fabric CA enrollment attribute carrying a client's role
+const roleAttribute = "role"
+
+// Role policies, deciding how the on-chain User.Role and the certificate role combine
+const (
+	rolePolicyOnChain   = "onchain"   // Only the on-chain User.Role counts
+	rolePolicyAttribute = "attribute" // Only the certificate role attribute counts
+	rolePolicyBoth      = "both"      // Both must be present and agree
+	rolePolicyEither    = "either"    // The caller acts under either of them
 )
 
-// Vote represents a vote cast by a voter
+// Vote is the public part of a ballot; who voted is recorded separately as a Participation
 type Vote struct {
+	VoteID     string `json:"vote_id"`
+	ElectionID string `json:"election_id"`
+	Receipt    string `json:"receipt"`
+}
+
+// PrivateBallot is the content of a ballot, salted so its hash in the block does not reveal the candidate
+type PrivateBallot struct {
 	VoteID      string `json:"vote_id"`
-	VoterID     string `json:"voter_id"`
 	ElectionID  string `json:"election_id"`
 	CandidateID string `json:"candidate_id"`
-	Receipt     string `json:"receipt"`
-	CreatedAt   string `json:"created_at"` // Timestamp of when the vote was cast
+	Governorate string `json:"governorate"` // Governorate of the voter when the ballot was cast
+	Salt        string `json:"salt"`        // Random secret from the ballot input, never written to public state
+}
+
+// BallotInput is the ballot a voter passes to CastVote through the transient map
+type BallotInput struct {
+	CandidateID string `json:"candidate_id"`
+	Nonce       string `json:"nonce"` // Secret kept by the voter, salts the receipt
+	Salt        string `json:"salt"`  // Random secret, salts the private ballot
+}
+
+// ReceiptVerification confirms a receipt belongs to a counted ballot without revealing the candidate
+type ReceiptVerification struct {
+	ElectionID string `json:"election_id"`
+	Receipt    string `json:"receipt"`
+	Recorded   bool   `json:"recorded"` // A public ballot carries this receipt
+	Counted    bool   `json:"counted"`  // The ballot's private content was committed to the collection, so tallies include it
+}
+
+// BallotHashVerification is the result of checking the private ballots of an election
+// against the hashes Fabric keeps of them on the ledger
+type BallotHashVerification struct {
+	ElectionID string   `json:"election_id"`
+	Checked    int      `json:"checked"`
+	Mismatched []string `json:"mismatched"` // Vote IDs whose private ballot does not match its ledger hash
+	Missing    []string `json:"missing"`    // Vote IDs with no hash on the ledger or no private ballot on this peer
+	Valid      bool     `json:"valid"`
+}
+
+// Participation proves that a voter took part in an election without recording their choice
+type Participation struct {
+	VoterID    string `json:"voter_id"`
+	ElectionID string `json:"election_id"`
+	CreatedAt  string `json:"created_at"` // Timestamp of when the vote was cast
+}
+
+// VoteReceipt is returned to the voter by CastVote
+type VoteReceipt struct {
+	VoteID  string `json:"vote_id"`
+	Receipt string `json:"receipt"`
 }
 
 // Election represents an election with its parameters
@@ -29,6 +151,8 @@ type Election struct {
 	EligibleGovernorates []string    `json:"eligible_governorates"`
 	Status               string      `json:"status"`
 	ElectionImage        string      `json:"election_image"`
+	TieBreakRule         string      `json:"tie_break_rule"`                           // runoff, lots or manual; empty means runoff
+	LotsSeed             string      `json:"lots_seed,omitempty" metadata:",optional"` // Committed by CreateElection, see determineResult
 }
 
 // Candidate represents a candidate in an election
@@ -38,6 +162,7 @@ type Candidate struct {
 	Party        string `json:"party"`
 	ProfileImage string `json:"profile_image"`
 	Description  string `json:"description"`
+	Withdrawn    bool   `json:"withdrawn"` // Withdrawn candidates keep their ID reserved but cannot receive votes
 }
 
 // User represents a registered voter in the system
@@ -50,10 +175,173 @@ type User struct {
 }
 
 type VoteTally struct {
-	ID         string         `json:"id"`          // Unique identifier for the tally
-	UserID     string         `json:"user_id"`     // ID of the user who created the tally
-	ElectionID string         `json:"election_id"` // TODO: index this column
-	Tallies    map[string]int `json:"tallies"`     // Map of candidateID -> vote count
-	CreatedAt  string         `json:"created_at"`  // Timestamp of when the tally was created
-	IsFinal    bool           `json:"is_final"`    // Indicates if this is the finalized tally
+	ID            string                    `json:"id"`                                    // Unique identifier for the tally
+	UserID        string                    `json:"user_id"`                               // ID of the user who created the tally
+	ElectionID    string                    `json:"election_id"`                           // ID of the tallied election, part of the tally key
+	Tallies       map[string]int            `json:"tallies"`                               // Map of candidateID -> vote count
+	ByGovernorate map[string]map[string]int `json:"by_governorate"`                        // Map of governorate -> candidateID -> vote count
+	CreatedAt     string                    `json:"created_at"`                            // Timestamp of when the tally was created
+	IsFinal       bool                      `json:"is_final"`                              // Indicates if this is the finalized tally
+	Version       int                       `json:"version"`                               // Position of the tally in the election's tally history, starting at 1
+	Result        *ElectionResult           `json:"result,omitempty" metadata:",optional"` // Ranking and winner, only on the final tally
+}
+
+// ElectionPatch holds the election fields UpdateElection may change.
+// Fields left out of the patch are not modified.
+type ElectionPatch struct {
+	Name                 *string      `json:"name,omitempty"`
+	Description          *string      `json:"description,omitempty"`
+	ElectionImage        *string      `json:"election_image,omitempty"`
+	Candidates           *[]Candidate `json:"candidates,omitempty"`
+	EligibleGovernorates *[]string    `json:"eligible_governorates,omitempty"`
+	StartTime            *string      `json:"start_time,omitempty"`
+	EndTime              *string      `json:"end_time,omitempty"`
+	TieBreakRule         *string      `json:"tie_break_rule,omitempty"`
+}
+
+// FieldChange records the old and new value of an updated field
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// ReceiptBoard is the Merkle root over all receipts of an election, committed when it ends
+type ReceiptBoard struct {
+	ElectionID  string `json:"election_id"`
+	MerkleRoot  string `json:"merkle_root"`
+	LeafCount   int    `json:"leaf_count"`
+	CommittedAt string `json:"committed_at"`
+}
+
+// InclusionProof is the Merkle authentication path from a receipt to the committed root
+type InclusionProof struct {
+	ElectionID string      `json:"election_id"`
+	Receipt    string      `json:"receipt"`
+	LeafIndex  int         `json:"leaf_index"`
+	LeafCount  int         `json:"leaf_count"`
+	MerkleRoot string      `json:"merkle_root"`
+	Path       []ProofStep `json:"path"` // Sibling hashes from the leaf level upwards
+}
+
+// ProofStep is one sibling hash of an inclusion proof and the side it is joined on
+type ProofStep struct {
+	Hash     string `json:"hash"`
+	Position string `json:"position"` // "left" or "right"
+}
+
+// Turnout summarises how many voters took part in an election
+type Turnout struct {
+	ElectionID       string                        `json:"election_id"`
+	Votes            int                           `json:"votes"`
+	RegisteredVoters int                           `json:"registered_voters"` // Voters registered in the eligible governorates
+	Rate             float64                       `json:"rate"`              // Votes / RegisteredVoters, 0 when nobody is registered
+	ByGovernorate    map[string]GovernorateTurnout `json:"by_governorate"`
+	Hourly           []TurnoutBucket               `json:"hourly"` // Votes per hour of the tx timestamp, oldest first
+}
+
+// GovernorateTurnout is the turnout of one governorate
+type GovernorateTurnout struct {
+	Votes            int     `json:"votes"`
+	RegisteredVoters int     `json:"registered_voters"`
+	Rate             float64 `json:"rate"`
+}
+
+// TurnoutBucket counts the votes cast during one hour
+type TurnoutBucket struct {
+	Hour  string `json:"hour"` // Start of the hour, RFC3339 in UTC
+	Votes int    `json:"votes"`
+}
+
+// RolePolicy records which role policy the chaincode enforces. Without one the
+// on-chain role is used.
+type RolePolicy struct {
+	Policy string `json:"policy"`
+	SetBy  string `json:"set_by"`
+	SetAt  string `json:"set_at"`
+}
+
+// Permission is the entry of the permission matrix for one transaction. Roles lists
+// who may submit it, empty leaving it open to any client; Statuses lists the election
+// statuses it is accepted in, empty for any. Statuses only apply to transactions
+// taking an election ID.
+type Permission struct {
+	Function string   `json:"function"`
+	Roles    []string `json:"roles"`
+	Statuses []string `json:"statuses"`
+	SetBy    string   `json:"set_by,omitempty" metadata:",optional"` // Empty while the default from the chaincode applies
+	SetAt    string   `json:"set_at,omitempty" metadata:",optional"`
+}
+
+// CertificationPolicy is the M-of-N rule the final tally of an election must meet
+// before its results can be published
+type CertificationPolicy struct {
+	ElectionID string   `json:"election_id"`
+	Required   int      `json:"required"` // M, the number of certifications needed
+	Signers    []string `json:"signers"`  // N, the commission and auditor user IDs allowed to certify
+	SetBy      string   `json:"set_by"`
+	SetAt      string   `json:"set_at"`
+}
+
+// Certification records that a signer approved the final tally with the given hash
+type Certification struct {
+	ElectionID  string `json:"election_id"`
+	TallyID     string `json:"tally_id"`
+	TallyHash   string `json:"tally_hash"` // Hex SHA-256 of the stored final tally
+	SignerID    string `json:"signer_id"`
+	CertifiedAt string `json:"certified_at"`
+}
+
+// CertificationStatus is the progress of an election's certification
+type CertificationStatus struct {
+	ElectionID     string               `json:"election_id"`
+	Policy         *CertificationPolicy `json:"policy"`
+	TallyHash      string               `json:"tally_hash"` // Empty until the final tally is proposed
+	Certifications []*Certification     `json:"certifications"`
+	Certified      bool                 `json:"certified"`
+}
+
+// Recount is the result of recounting an election from its ballots and comparing
+// it with a stored tally
+type Recount struct {
+	RecountID       string                   `json:"recount_id"` // ID of the recount transaction
+	ElectionID      string                   `json:"election_id"`
+	ComparedTallyID string                   `json:"compared_tally_id"` // The final tally, or the latest one if none is final yet
+	BallotCount     int                      `json:"ballot_count"`
+	Tallies         map[string]int           `json:"tallies"` // Map of candidateID -> recounted votes
+	Discrepancies   map[string]CountMismatch `json:"discrepancies"`
+	RequestedBy     string                   `json:"requested_by"`
+	CreatedAt       string                   `json:"created_at"`
+}
+
+// CountMismatch is a candidate whose recounted votes differ from the stored tally
+type CountMismatch struct {
+	Recounted int `json:"recounted"`
+	Stored    int `json:"stored"`
+}
+
+// ElectionResult ranks the candidates of a final tally and names the winner
+type ElectionResult struct {
+	Ranking      []RankedCandidate `json:"ranking"`
+	Winners      []string          `json:"winners"` // Empty while a runoff or commission decision is pending
+	Tied         []string          `json:"tied"`    // Candidates tied for first place, if any
+	TieBreakRule string            `json:"tie_break_rule"`
+	Outcome      string            `json:"outcome"`
+	Reasoning    string            `json:"reasoning"`
+	Seed         string            `json:"seed,omitempty" metadata:",optional"` // Hex seed the lots were drawn with
+}
+
+// RankedCandidate is a candidate's place in the result; tied candidates share a rank
+type RankedCandidate struct {
+	CandidateID string `json:"candidate_id"`
+	Votes       int    `json:"votes"`
+	Rank        int    `json:"rank"`
+}
+
+// TieResolution records the commission's decision on a tie under the manual rule
+type TieResolution struct {
+	ElectionID  string `json:"election_id"`
+	CandidateID string `json:"candidate_id"`
+	Reasoning   string `json:"reasoning"`
+	DecidedBy   string `json:"decided_by"`
+	DecidedAt   string `json:"decided_at"`
 }
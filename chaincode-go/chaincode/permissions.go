@@ -0,0 +1,252 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// defaultPermissions is the permission matrix used for transactions the ledger holds
+// no entry for. The election lifecycle checks inside each transaction apply on top of
+// it. InitAdmin and MigrateLegacyUsers are not listed: they bootstrap or repair the
+// user registry and are gated on an admin identity of the governance MSP instead.
+var defaultPermissions = map[string]Permission{
+	// Ledger
+	"InitLedger":    {Roles: []string{roleAdmin}},
+	"GetWorldState": {Roles: []string{roleAdmin}},
+
+	// Elections and candidates
+	"CreateElection":       {Roles: []string{roleCommission, roleAdmin}},
+	"GetElection":          {},
+	"GetAllElections":      {},
+	"UpdateElection":       {Roles: []string{roleCommission}, Statuses: []string{statusScheduled}},
+	"AddCandidate":         {Roles: []string{roleCommission}, Statuses: []string{statusScheduled}},
+	"WithdrawCandidate":    {Roles: []string{roleCommission}, Statuses: []string{statusScheduled}},
+	"ReorderCandidates":    {Roles: []string{roleCommission}, Statuses: []string{statusScheduled}},
+	"UpdateElectionStatus": {Roles: []string{roleCommission, roleAdmin}},
+	"ClearElections":       {Roles: []string{roleAdmin}},
+
+	// Votes and receipts
+	"CastVote":                 {Statuses: []string{statusLive}},
+	"GetVote":                  {},
+	"GetAllVotes":              {},
+	"GetVotesByElection":       {},
+	"CountVotesByElection":     {},
+	"MigrateVoteIndex":         {Roles: []string{roleCommission, roleAdmin}},
+	"GetParticipation":         {},
+	"VerifyReceipt":            {},
+	"VerifyBallotHashes":       {},
+	"GetReceiptBoard":          {},
+	"GetReceiptInclusionProof": {},
+	"GetTurnout":               {},
+
+	// Tallies and results, also subject to the results embargo
+	"PreviewTally":           {},
+	"ComputeVoteTally":       {Roles: []string{roleCommission, roleAuditor, roleAdmin}},
+	"ComputeFinalTally":      {Roles: []string{roleCommission, roleAdmin}, Statuses: []string{statusEnded, statusPublished}},
+	"GetTally":               {},
+	"GetLatestTally":         {},
+	"GetTallyHistory":        {},
+	"GetTallyByGovernorate":  {},
+	"SetCertificationPolicy": {Roles: []string{roleCommission, roleAdmin}, Statuses: []string{statusScheduled}},
+	"CertifyTally":           {Roles: []string{roleCommission, roleAuditor}, Statuses: []string{statusEnded}},
+	"GetCertificationStatus": {},
+	"RecountElection":        {Roles: []string{roleAuditor, roleCommission}},
+	"ResolveTie":             {Roles: []string{roleCommission}, Statuses: []string{statusEnded}},
+	"GetElectionResult":      {},
+
+	// Users and access control. Unregistered clients registering themselves as
+	// voters are not subject to the RegisterUser entry.
+	"RegisterUser":       {Roles: []string{roleAdmin, roleCommission}},
+	"GetUser":            {},
+	"GetAllUsers":        {},
+	"IsUserAdmin":        {},
+	"SetUserRole":        {Roles: []string{roleAdmin}},
+	"MigrateVoterIndex":  {Roles: []string{roleCommission, roleAdmin}},
+	"UpdateUserStatus":   {Roles: []string{roleCommission, roleAuditor}},
+	"GetUserRevocations": {Roles: []string{roleCommission, roleAuditor}},
+	"SetRolePolicy":      {Roles: []string{roleAdmin}},
+	"GetRolePolicy":      {},
+	"SetPermission":      {Roles: []string{roleAdmin}},
+	"GetPermissions":     {},
+}
+
+// pinnedPermissions are the entries that govern access control itself. They always
+// apply their default, so the matrix cannot be used to hand control of it to another role.
+var pinnedPermissions = []string{"SetPermission", "SetRolePolicy"}
+
+// SetPermission replaces the permission matrix entry of a transaction with the roles
+// and election statuses given as JSON arrays. Like SetRolePolicy it is also open to an
+// admin identity of the governance MSP. Restricted transactions cannot be opened to every client.
+func (s *VotingContract) SetPermission(ctx contractapi.TransactionContextInterface, function string, rolesJSON string, statusesJSON string) error {
+	defaultPermission, ok := defaultPermissions[function]
+	if !ok {
+		return fmt.Errorf("transaction %s has no permission entry", function)
+	}
+	if slices.Contains(pinnedPermissions, function) {
+		return fmt.Errorf("the permission entry of %s is fixed", function)
+	}
+
+	var roles []string
+	err := json.Unmarshal([]byte(rolesJSON), &roles)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal roles: %v", err)
+	}
+	validRoles := []string{roleVoter, roleCommission, roleAuditor, roleAdmin}
+	for _, role := range roles {
+		if !slices.Contains(validRoles, role) {
+			return fmt.Errorf("invalid role: %s. Must be one of: %v", role, validRoles)
+		}
+	}
+	if len(roles) == 0 && len(defaultPermission.Roles) > 0 {
+		return fmt.Errorf("transaction %s is restricted by default and cannot be opened to every client", function)
+	}
+
+	var statuses []string
+	err = json.Unmarshal([]byte(statusesJSON), &statuses)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal statuses: %v", err)
+	}
+	validStatuses := []string{statusScheduled, statusLive, statusEnded, statusPublished, statusCancelled}
+	for _, status := range statuses {
+		if !slices.Contains(validStatuses, status) {
+			return fmt.Errorf("invalid status: %s. Must be one of: %v", status, validStatuses)
+		}
+	}
+
+	isAdmin, err := isGovernanceAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		if err := checkPermission(ctx, "SetPermission", ""); err != nil {
+			return err
+		}
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	setAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	permission := Permission{
+		Function: function,
+		Roles:    roles,
+		Statuses: statuses,
+		SetBy:    callerID,
+		SetAt:    setAt,
+	}
+	permissionJSON, err := json.Marshal(permission)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permission: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(permissionPrefix+function, permissionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save permission: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("permission_set", permissionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return nil
+}
+
+// GetPermissions returns the permission matrix in force, sorted by transaction name
+func (s *VotingContract) GetPermissions(ctx contractapi.TransactionContextInterface) ([]*Permission, error) {
+	if err := checkPermission(ctx, "GetPermissions", ""); err != nil {
+		return nil, err
+	}
+
+	functions := make([]string, 0, len(defaultPermissions))
+	for function := range defaultPermissions {
+		functions = append(functions, function)
+	}
+	sort.Strings(functions)
+
+	permissions := make([]*Permission, 0, len(functions))
+	for _, function := range functions {
+		permission, err := getPermission(ctx, function)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+// checkPermission enforces the permission matrix entry of a transaction. electionID
+// is empty for transactions that are not about an election.
+func checkPermission(ctx contractapi.TransactionContextInterface, function string, electionID string) error {
+	permission, err := getPermission(ctx, function)
+	if err != nil {
+		return err
+	}
+
+	if len(permission.Roles) > 0 {
+		if _, err := authorize(ctx, "call "+function, permission.Roles...); err != nil {
+			return err
+		}
+	}
+
+	if len(permission.Statuses) > 0 && electionID != "" {
+		election, err := getElection(ctx, electionID)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(permission.Statuses, election.Status) {
+			return fmt.Errorf("%s is not allowed while election %s is %s", function, electionID, election.Status)
+		}
+	}
+
+	return nil
+}
+
+// getPermission reads the permission matrix entry of a transaction from the ledger,
+// falling back to its default. Pinned entries always use their default, even if an
+// entry was stored for them before they were pinned.
+func getPermission(ctx contractapi.TransactionContextInterface, function string) (*Permission, error) {
+	defaultPermission, ok := defaultPermissions[function]
+	if !ok {
+		return nil, fmt.Errorf("transaction %s has no permission entry", function)
+	}
+	if slices.Contains(pinnedPermissions, function) {
+		return newDefaultPermission(function, defaultPermission), nil
+	}
+
+	permissionJSON, err := ctx.GetStub().GetState(permissionPrefix + function)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission: %v", err)
+	}
+	if permissionJSON == nil {
+		return newDefaultPermission(function, defaultPermission), nil
+	}
+
+	var permission Permission
+	err = json.Unmarshal(permissionJSON, &permission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal permission: %v", err)
+	}
+
+	return &permission, nil
+}
+
+// newDefaultPermission copies the default entry of a transaction
+func newDefaultPermission(function string, defaultPermission Permission) *Permission {
+	return &Permission{
+		Function: function,
+		Roles:    append([]string{}, defaultPermission.Roles...),
+		Statuses: append([]string{}, defaultPermission.Statuses...),
+	}
+}
@@ -0,0 +1,195 @@
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// The receipt board is a Merkle tree over every receipt of an election, committed
+// when the election ends. Leaves are ordered by receipt and hashed as
+// SHA-256(0x00 || receipt), inner nodes as SHA-256(0x01 || left || right).
+// A node without a sibling is carried up to the next level unchanged.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// commitReceiptBoard stores the Merkle root over all receipts of an election
+func commitReceiptBoard(ctx contractapi.TransactionContextInterface, electionID string) (*ReceiptBoard, error) {
+	receipts, err := getElectionReceipts(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	committedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := buildMerkleTree(receipts)
+	board := ReceiptBoard{
+		ElectionID:  electionID,
+		MerkleRoot:  hex.EncodeToString(levels[len(levels)-1][0]),
+		LeafCount:   len(receipts),
+		CommittedAt: committedAt,
+	}
+
+	boardJSON, err := json.Marshal(board)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutState(receiptBoardPrefix+electionID, boardJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save receipt board: %v", err)
+	}
+
+	return &board, nil
+}
+
+// GetReceiptBoard returns the Merkle root committed for an election's receipts
+func (s *VotingContract) GetReceiptBoard(ctx contractapi.TransactionContextInterface, electionID string) (*ReceiptBoard, error) {
+	if err := checkPermission(ctx, "GetReceiptBoard", electionID); err != nil {
+		return nil, err
+	}
+
+	return getReceiptBoard(ctx, electionID)
+}
+
+// getReceiptBoard reads the receipt board of an election
+func getReceiptBoard(ctx contractapi.TransactionContextInterface, electionID string) (*ReceiptBoard, error) {
+	boardJSON, err := ctx.GetStub().GetState(receiptBoardPrefix + electionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if boardJSON == nil {
+		return nil, fmt.Errorf("no receipt board has been committed for election %s", electionID)
+	}
+
+	var board ReceiptBoard
+	err = json.Unmarshal(boardJSON, &board)
+	if err != nil {
+		return nil, err
+	}
+
+	return &board, nil
+}
+
+// GetReceiptInclusionProof returns the Merkle authentication path from a receipt to
+// the committed root, so anyone can check the ballot is in the tallied set
+func (s *VotingContract) GetReceiptInclusionProof(ctx contractapi.TransactionContextInterface, electionID string, receipt string) (*InclusionProof, error) {
+	if err := checkPermission(ctx, "GetReceiptInclusionProof", electionID); err != nil {
+		return nil, err
+	}
+
+	board, err := getReceiptBoard(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts, err := getElectionReceipts(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	leafIndex := -1
+	for i, candidate := range receipts {
+		if candidate == receipt {
+			leafIndex = i
+			break
+		}
+	}
+	if leafIndex == -1 {
+		return nil, fmt.Errorf("receipt %s is not on the board of election %s", receipt, electionID)
+	}
+
+	levels := buildMerkleTree(receipts)
+	if root := hex.EncodeToString(levels[len(levels)-1][0]); root != board.MerkleRoot {
+		return nil, fmt.Errorf("receipts of election %s no longer match the committed root", electionID)
+	}
+
+	proof := InclusionProof{
+		ElectionID: electionID,
+		Receipt:    receipt,
+		LeafIndex:  leafIndex,
+		LeafCount:  board.LeafCount,
+		MerkleRoot: board.MerkleRoot,
+		Path:       []ProofStep{},
+	}
+
+	index := leafIndex
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			position := "right"
+			if sibling < index {
+				position = "left"
+			}
+			proof.Path = append(proof.Path, ProofStep{Hash: hex.EncodeToString(level[sibling]), Position: position})
+		}
+		index /= 2
+	}
+
+	return &proof, nil
+}
+
+// getElectionReceipts returns the receipts of an election in key order
+func getElectionReceipts(ctx contractapi.TransactionContextInterface, electionID string) ([]string, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(receiptObjectType, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts: %v", err)
+	}
+	defer iterator.Close()
+
+	receipts := []string{}
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next receipt: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		receipts = append(receipts, attributes[1])
+	}
+
+	return receipts, nil
+}
+
+// buildMerkleTree returns every level of the tree, from the leaves up to the root
+func buildMerkleTree(receipts []string) [][][]byte {
+	if len(receipts) == 0 {
+		emptyRoot := sha256.Sum256(nil)
+		return [][][]byte{{emptyRoot[:]}}
+	}
+
+	leaves := make([][]byte, len(receipts))
+	for i, receipt := range receipts {
+		leaf := sha256.Sum256(append([]byte{merkleLeafPrefix}, receipt...))
+		leaves[i] = leaf[:]
+	}
+
+	levels := [][][]byte{leaves}
+	for level := leaves; len(level) > 1; {
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node := sha256.Sum256(bytes.Join([][]byte{{merkleNodePrefix}, level[i], level[i+1]}, nil))
+			next = append(next, node[:])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels
+}
@@ -0,0 +1,116 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// RecountElection recounts an election from the ballots in the private collection and
+// compares the result with the final tally, or the latest stored tally if none is
+// final yet. The recount is stored on its own so the certified result is never touched.
+// It must be submitted to a peer of an organization that is a member of the ballot collection.
+func (s *VotingContract) RecountElection(ctx contractapi.TransactionContextInterface, electionID string) (*Recount, error) {
+	if err := checkPermission(ctx, "RecountElection", electionID); err != nil {
+		return nil, err
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureCanViewResults(ctx, election); err != nil {
+		return nil, err
+	}
+	if err := ensureNotLive(election); err != nil {
+		return nil, err
+	}
+
+	stored, err := getFinalOrLatestTally(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := getVotesByElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	tallies := newCandidateCounts(election)
+	for _, vote := range votes {
+		ballot, err := getPrivateBallot(ctx, electionID, vote.VoteID)
+		if err != nil {
+			return nil, err
+		}
+		tallies[ballot.CandidateID]++
+	}
+
+	discrepancies := make(map[string]CountMismatch)
+	for candidateID, recounted := range tallies {
+		if recounted != stored.Tallies[candidateID] {
+			discrepancies[candidateID] = CountMismatch{Recounted: recounted, Stored: stored.Tallies[candidateID]}
+		}
+	}
+	for candidateID, count := range stored.Tallies {
+		if _, ok := tallies[candidateID]; !ok && count != 0 {
+			discrepancies[candidateID] = CountMismatch{Recounted: 0, Stored: count}
+		}
+	}
+
+	createdAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recount := Recount{
+		RecountID:       ctx.GetStub().GetTxID(),
+		ElectionID:      electionID,
+		ComparedTallyID: stored.ID,
+		BallotCount:     len(votes),
+		Tallies:         tallies,
+		Discrepancies:   discrepancies,
+		RequestedBy:     callerID,
+		CreatedAt:       createdAt,
+	}
+
+	recountKey, err := ctx.GetStub().CreateCompositeKey(recountObjectType, []string{electionID, recount.RecountID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	recountJSON, err := json.Marshal(recount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recount: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(recountKey, recountJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save recount: %v", err)
+	}
+
+	if len(discrepancies) > 0 {
+		eventPayload, err := json.Marshal(map[string]interface{}{
+			"election_id":       electionID,
+			"recount_id":        recount.RecountID,
+			"compared_tally_id": recount.ComparedTallyID,
+			"discrepancies":     discrepancies,
+			"requested_by":      callerID,
+			"timestamp":         createdAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal event payload: %v", err)
+		}
+
+		err = ctx.GetStub().SetEvent("recount_discrepancy", eventPayload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to emit event: %v", err)
+		}
+	}
+
+	return &recount, nil
+}
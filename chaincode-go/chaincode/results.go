@@ -0,0 +1,257 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// validateTieBreakRule checks an election's tie-break rule, empty meaning runoff
+func validateTieBreakRule(rule string) error {
+	switch rule {
+	case "", tieBreakRunoff, tieBreakLots, tieBreakManual:
+		return nil
+	default:
+		return fmt.Errorf("invalid tie-break rule %s, expected %s, %s or %s", rule, tieBreakRunoff, tieBreakLots, tieBreakManual)
+	}
+}
+
+// rankCandidates orders candidates by votes, most first. Tied candidates share a
+// rank and are listed by candidate ID so the ranking is the same on every peer.
+func rankCandidates(tallies map[string]int) []RankedCandidate {
+	ranking := []RankedCandidate{}
+	for candidateID, votes := range tallies {
+		ranking = append(ranking, RankedCandidate{CandidateID: candidateID, Votes: votes})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Votes != ranking[j].Votes {
+			return ranking[i].Votes > ranking[j].Votes
+		}
+		return ranking[i].CandidateID < ranking[j].CandidateID
+	})
+
+	for i := range ranking {
+		if i > 0 && ranking[i].Votes == ranking[i-1].Votes {
+			ranking[i].Rank = ranking[i-1].Rank
+		} else {
+			ranking[i].Rank = i + 1
+		}
+	}
+
+	return ranking
+}
+
+// newLotsSeed derives the lots seed an election commits to from the tx creating it
+func newLotsSeed(ctx contractapi.TransactionContextInterface) string {
+	seed := sha256.Sum256([]byte(ctx.GetStub().GetTxID()))
+	return hex.EncodeToString(seed[:])
+}
+
+// drawLots picks one of the tied candidates. The seed hashes the election's committed
+// lots seed with the final tally, so the submitter of the final tally cannot retry
+// with other tx IDs until the draw suits them, and the creator of the election could
+// not know the tally the seed would meet.
+func drawLots(election *Election, tallies map[string]int, tied []string) (string, string, error) {
+	talliesJSON, err := json.Marshal(tallies)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal tallies: %v", err)
+	}
+
+	seed := sha256.Sum256([]byte(election.LotsSeed + "\x00" + election.ElectionID + "\x00" + string(talliesJSON)))
+	drawn := tied[binary.BigEndian.Uint64(seed[:8])%uint64(len(tied))]
+	return drawn, hex.EncodeToString(seed[:]), nil
+}
+
+// determineResult ranks a final tally and applies the election's tie-break rule
+func determineResult(election *Election, tallies map[string]int) (*ElectionResult, error) {
+	rule := election.TieBreakRule
+	if rule == "" {
+		rule = tieBreakRunoff
+	}
+
+	result := ElectionResult{
+		Ranking:      rankCandidates(tallies),
+		Winners:      []string{},
+		Tied:         []string{},
+		TieBreakRule: rule,
+	}
+	if len(result.Ranking) == 0 {
+		return nil, fmt.Errorf("election %s has no candidates to rank", election.ElectionID)
+	}
+
+	for _, candidate := range result.Ranking {
+		if candidate.Rank == 1 {
+			result.Tied = append(result.Tied, candidate.CandidateID)
+		}
+	}
+	top := result.Ranking[0]
+
+	if len(result.Tied) == 1 {
+		result.Tied = []string{}
+		result.Winners = []string{top.CandidateID}
+		result.Outcome = outcomeWinner
+		result.Reasoning = fmt.Sprintf("%s has the most votes with %d", top.CandidateID, top.Votes)
+		return &result, nil
+	}
+
+	tie := fmt.Sprintf("%s tied for first place with %d votes", strings.Join(result.Tied, ", "), top.Votes)
+	switch rule {
+	case tieBreakLots:
+		if election.LotsSeed == "" {
+			// Elections created before seeds were committed have nothing safe to draw from
+			result.Outcome = outcomeAwaitingDecision
+			result.Reasoning = fmt.Sprintf("%s; the election has no committed lots seed, so the election commission must decide the winner", tie)
+			break
+		}
+		drawn, seed, err := drawLots(election, tallies, result.Tied)
+		if err != nil {
+			return nil, err
+		}
+		result.Winners = []string{drawn}
+		result.Outcome = outcomeLotsDrawn
+		result.Seed = seed
+		result.Reasoning = fmt.Sprintf("%s; lots drawn with the seed committed when the election was created and the final tally picked %s", tie, drawn)
+	case tieBreakManual:
+		result.Outcome = outcomeAwaitingDecision
+		result.Reasoning = fmt.Sprintf("%s; the election commission must decide the winner", tie)
+	default:
+		result.Outcome = outcomeRunoffRequired
+		result.Reasoning = fmt.Sprintf("%s; a runoff between them is required", tie)
+	}
+
+	return &result, nil
+}
+
+// ResolveTie records the commission's choice of winner for a final tally tied under
+// the manual rule. It must be made before the final tally is certified.
+func (s *VotingContract) ResolveTie(ctx contractapi.TransactionContextInterface, electionID string, candidateID string, reasoning string) error {
+	if err := checkPermission(ctx, "ResolveTie", electionID); err != nil {
+		return err
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election.Status != statusEnded {
+		return fmt.Errorf("election %s is %s, ties can only be resolved once it has ended", electionID, election.Status)
+	}
+
+	final, err := s.getTally(ctx, electionID, finalTallyID)
+	if err != nil {
+		return err
+	}
+	if final.Result == nil || final.Result.Outcome != outcomeAwaitingDecision {
+		return fmt.Errorf("the final tally of election %s has no tie awaiting a commission decision", electionID)
+	}
+	if !slices.Contains(final.Result.Tied, candidateID) {
+		return fmt.Errorf("candidate %s is not tied for first place in election %s", candidateID, electionID)
+	}
+	if reasoning == "" {
+		return fmt.Errorf("the reasoning of the decision is required")
+	}
+
+	existing, err := getTieResolution(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("the tie in election %s has already been resolved", electionID)
+	}
+
+	decidedAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	resolution := TieResolution{
+		ElectionID:  electionID,
+		CandidateID: candidateID,
+		Reasoning:   reasoning,
+		DecidedBy:   callerID,
+		DecidedAt:   decidedAt,
+	}
+	resolutionJSON, err := json.Marshal(resolution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tie resolution: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(tieResolutionPrefix+electionID, resolutionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save tie resolution: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("tie_resolved", resolutionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return nil
+}
+
+// GetElectionResult returns the ranked result of the final tally, with the
+// commission's decision applied when a manual tie has been resolved
+func (s *VotingContract) GetElectionResult(ctx contractapi.TransactionContextInterface, electionID string) (*ElectionResult, error) {
+	if err := checkPermission(ctx, "GetElectionResult", electionID); err != nil {
+		return nil, err
+	}
+
+	return s.getElectionResult(ctx, electionID)
+}
+
+// getElectionResult builds the result of an election from its final tally
+func (s *VotingContract) getElectionResult(ctx contractapi.TransactionContextInterface, electionID string) (*ElectionResult, error) {
+	final, err := s.getTally(ctx, electionID, finalTallyID)
+	if err != nil {
+		return nil, err
+	}
+	if final.Result == nil {
+		return nil, fmt.Errorf("the final tally of election %s has no result", electionID)
+	}
+
+	result := final.Result
+	if result.Outcome == outcomeAwaitingDecision {
+		resolution, err := getTieResolution(ctx, electionID)
+		if err != nil {
+			return nil, err
+		}
+		if resolution != nil {
+			result.Winners = []string{resolution.CandidateID}
+			result.Outcome = outcomeCommissionChoice
+			result.Reasoning = fmt.Sprintf("%s; %s chose %s: %s", result.Reasoning, resolution.DecidedBy, resolution.CandidateID, resolution.Reasoning)
+		}
+	}
+
+	return result, nil
+}
+
+// getTieResolution reads the commission's tie decision for an election, nil if there is none
+func getTieResolution(ctx contractapi.TransactionContextInterface, electionID string) (*TieResolution, error) {
+	resolutionJSON, err := ctx.GetStub().GetState(tieResolutionPrefix + electionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if resolutionJSON == nil {
+		return nil, nil
+	}
+
+	var resolution TieResolution
+	err = json.Unmarshal(resolutionJSON, &resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resolution, nil
+}
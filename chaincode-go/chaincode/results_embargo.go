@@ -0,0 +1,77 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// resultViewers lists, per election status, the roles allowed to see tallies and
+// vote records before the results are published. Once an election is published
+// its results are public.
+var resultViewers = map[string][]string{
+	statusScheduled: {roleAuditor, roleCommission, roleAdmin},
+	statusLive:      {roleAuditor},
+	statusEnded:     {roleAuditor, roleCommission, roleAdmin},
+	statusCancelled: {roleAuditor, roleCommission, roleAdmin},
+}
+
+// ResultsEmbargoedError is returned when a caller queries results they may not see yet
+type ResultsEmbargoedError struct {
+	ElectionID string
+	Status     string
+	Role       string
+}
+
+func (e *ResultsEmbargoedError) Error() string {
+	return fmt.Sprintf("results of election %s are embargoed while it is %s: role %s cannot view them before publication", e.ElectionID, e.Status, e.Role)
+}
+
+// canViewResults reports whether the caller may see the results of an election
+func canViewResults(ctx contractapi.TransactionContextInterface, election *Election) (bool, string, error) {
+	if election.Status == statusPublished {
+		return true, "", nil
+	}
+
+	caller, err := authorize(ctx, "")
+	if err != nil {
+		return false, "", err
+	}
+
+	return caller.hasRole(resultViewers[election.Status]...), caller.role(), nil
+}
+
+// ensureCanViewResults fails with a ResultsEmbargoedError if the caller may not see
+// the results of an election yet
+func ensureCanViewResults(ctx contractapi.TransactionContextInterface, election *Election) error {
+	allowed, role, err := canViewResults(ctx, election)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &ResultsEmbargoedError{ElectionID: election.ElectionID, Status: election.Status, Role: role}
+	}
+
+	return nil
+}
+
+// ensureNotLive fails while an election is live. Whatever a transaction writes ends up
+// in blocks every organization reads, so counts are only stored once voting is over;
+// until then they can only be previewed.
+func ensureNotLive(election *Election) error {
+	if election.Status == statusLive {
+		return fmt.Errorf("election %s is live, its counts can only be previewed until voting ends", election.ElectionID)
+	}
+
+	return nil
+}
+
+// ensureCanViewElectionResults loads an election and checks the caller may see its results
+func (s *VotingContract) ensureCanViewElectionResults(ctx contractapi.TransactionContextInterface, electionID string) error {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+
+	return ensureCanViewResults(ctx, election)
+}
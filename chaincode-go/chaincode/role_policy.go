@@ -0,0 +1,91 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// validRolePolicies lists the role policies SetRolePolicy accepts
+var validRolePolicies = []string{rolePolicyOnChain, rolePolicyAttribute, rolePolicyBoth, rolePolicyEither}
+
+// SetRolePolicy chooses how the role attribute of client certificates and the on-chain
+// user role combine. Admins can change it, and so can an admin identity of the
+// governance MSP, so a policy that leaves no admin able to act can still be reverted.
+func (s *VotingContract) SetRolePolicy(ctx contractapi.TransactionContextInterface, policy string) error {
+	if !slices.Contains(validRolePolicies, policy) {
+		return fmt.Errorf("invalid role policy: %s. Must be one of: %v", policy, validRolePolicies)
+	}
+
+	isAdmin, err := isGovernanceAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		if err := checkPermission(ctx, "SetRolePolicy", ""); err != nil {
+			return err
+		}
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	setAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	rolePolicy := RolePolicy{
+		Policy: policy,
+		SetBy:  callerID,
+		SetAt:  setAt,
+	}
+	rolePolicyJSON, err := json.Marshal(rolePolicy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal role policy: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(rolePolicyKey, rolePolicyJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save role policy: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("role_policy_set", rolePolicyJSON)
+	if err != nil {
+		return fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return nil
+}
+
+// GetRolePolicy returns the role policy in force
+func (s *VotingContract) GetRolePolicy(ctx contractapi.TransactionContextInterface) (*RolePolicy, error) {
+	if err := checkPermission(ctx, "GetRolePolicy", ""); err != nil {
+		return nil, err
+	}
+
+	return getRolePolicy(ctx)
+}
+
+// getRolePolicy reads the role policy, defaulting to the on-chain role
+func getRolePolicy(ctx contractapi.TransactionContextInterface) (*RolePolicy, error) {
+	rolePolicyJSON, err := ctx.GetStub().GetState(rolePolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role policy: %v", err)
+	}
+	if rolePolicyJSON == nil {
+		return &RolePolicy{Policy: rolePolicyOnChain}, nil
+	}
+
+	var rolePolicy RolePolicy
+	err = json.Unmarshal(rolePolicyJSON, &rolePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal role policy: %v", err)
+	}
+
+	return &rolePolicy, nil
+}
@@ -0,0 +1,367 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Tallies are stored per computation under tally~electionID~tallyID and are never
+// overwritten, so the history of an election's tallies stays on the ledger.
+
+// computeTally sums the ballots of an election and cross-checks them against the
+// participation records
+func computeTally(ctx contractapi.TransactionContextInterface, election *Election) (map[string]int, map[string]map[string]int, error) {
+	// Sum the ballot collection rather than looking up the ballot of every vote
+	tally, byGovernorate, err := sumBallots(ctx, election)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Every participation record must be backed by exactly one ballot
+	participationCount, err := countParticipation(ctx, election.ElectionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ballotCount := 0
+	for _, count := range tally {
+		ballotCount += count
+	}
+	if ballotCount != participationCount {
+		return nil, nil, fmt.Errorf("election %s has %d ballots but %d participation records", election.ElectionID, ballotCount, participationCount)
+	}
+
+	return tally, byGovernorate, nil
+}
+
+// saveTally computes the tally of an election and stores it as the next version
+// of the election's tally history
+func (s *VotingContract) saveTally(ctx contractapi.TransactionContextInterface, tallyID string, electionID string, isFinal bool) (*VoteTally, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get election %s: %v", electionID, err)
+	}
+	if err := ensureCanViewResults(ctx, election); err != nil {
+		return nil, err
+	}
+	if err := ensureNotLive(election); err != nil {
+		return nil, err
+	}
+
+	tallyKey, err := ctx.GetStub().CreateCompositeKey(tallyObjectType, []string{electionID, tallyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(tallyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("tally %s already exists for election %s", tallyID, electionID)
+	}
+
+	voteTally, err := newVoteTally(ctx, tallyID, election)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := getTallyHistory(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	voteTally.IsFinal = isFinal
+	voteTally.Version = len(history) + 1
+
+	// The final tally also records the ranking and how the winner was decided
+	if isFinal {
+		voteTally.Result, err = determineResult(election, voteTally.Tallies)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tallyJSON, err := json.Marshal(voteTally)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vote tally: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(tallyKey, tallyJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save vote tally: %v", err)
+	}
+
+	return voteTally, nil
+}
+
+// newVoteTally computes the current tally of an election without storing it
+func newVoteTally(ctx contractapi.TransactionContextInterface, tallyID string, election *Election) (*VoteTally, error) {
+	tallies, byGovernorate, err := computeTally(ctx, election)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := getUserId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	createdAt, err := getTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VoteTally{
+		ID:            tallyID,
+		UserID:        clientID,
+		ElectionID:    election.ElectionID,
+		Tallies:       tallies,
+		ByGovernorate: byGovernorate,
+		CreatedAt:     createdAt,
+	}, nil
+}
+
+// PreviewTally returns the current tally of an election without writing state or
+// emitting events. It is meant to be evaluated, not submitted, so dashboards can
+// refresh it without conflicting with concurrent votes.
+func (s *VotingContract) PreviewTally(ctx contractapi.TransactionContextInterface, electionID string) (*VoteTally, error) {
+	if err := checkPermission(ctx, "PreviewTally", electionID); err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get election %s: %v", electionID, err)
+	}
+	if err := ensureCanViewResults(ctx, election); err != nil {
+		return nil, err
+	}
+
+	return newVoteTally(ctx, "", election)
+}
+
+// ComputeVoteTally calculates the tally of an election that is no longer live and stores it
+// under tallyID; a live election can only be previewed with PreviewTally
+func (s *VotingContract) ComputeVoteTally(ctx contractapi.TransactionContextInterface, tallyID string, electionID string) (*VoteTally, error) {
+	if err := checkPermission(ctx, "ComputeVoteTally", electionID); err != nil {
+		return nil, err
+	}
+
+	if tallyID == "" {
+		return nil, fmt.Errorf("tally ID is required")
+	}
+	if tallyID == finalTallyID {
+		return nil, fmt.Errorf("tally ID %s is reserved for the final tally", finalTallyID)
+	}
+
+	voteTally, err := s.saveTally(ctx, tallyID, electionID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Emit an event with the election ID for the tally computation
+	tallyEventPayload, err := json.Marshal(map[string]interface{}{
+		"electionId": electionID,
+		"tally_id":   voteTally.ID,
+		"version":    voteTally.Version,
+		"user_id":    voteTally.UserID,
+		"timestamp":  voteTally.CreatedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tally event payload: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("tally_computed", tallyEventPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to emit tally_computed event: %v", err)
+	}
+
+	return voteTally, nil
+}
+
+// ComputeFinalTally proposes the final tally for an election once voting has ended.
+// The final tally is written once and can never be recomputed or replaced; it is
+// then certified through CertifyTally before the results can be published.
+func (s *VotingContract) ComputeFinalTally(ctx contractapi.TransactionContextInterface, electionID string) (*VoteTally, error) {
+	if err := checkPermission(ctx, "ComputeFinalTally", electionID); err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election.Status != statusEnded && election.Status != statusPublished {
+		return nil, fmt.Errorf("election %s is %s, the final tally can only be computed once it has ended", electionID, election.Status)
+	}
+
+	tally, err := s.saveTally(ctx, finalTallyID, electionID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute final tally: %v", err)
+	}
+
+	// Emit an event for the final tally computation
+	eventPayload := map[string]interface{}{
+		"election_id": electionID,
+		"tally_id":    tally.ID,
+		"version":     tally.Version,
+		"is_final":    true,
+		"winners":     tally.Result.Winners,
+		"outcome":     tally.Result.Outcome,
+		"timestamp":   tally.CreatedAt,
+	}
+
+	eventPayloadJSON, err := json.Marshal(eventPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal event payload: %v", err)
+	}
+
+	err = ctx.GetStub().SetEvent("final_tally_computed", eventPayloadJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to emit event: %v", err)
+	}
+
+	return tally, nil
+}
+
+// GetTallyByGovernorate returns the current candidateID -> vote count of the ballots
+// cast by voters of one governorate
+func (s *VotingContract) GetTallyByGovernorate(ctx contractapi.TransactionContextInterface, electionID string, governorate string) (map[string]int, error) {
+	if err := checkPermission(ctx, "GetTallyByGovernorate", electionID); err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureCanViewResults(ctx, election); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(election.EligibleGovernorates, governorate) {
+		return nil, fmt.Errorf("governorate %s is not eligible in election %s", governorate, electionID)
+	}
+
+	return sumGovernorateBallots(ctx, election, governorate)
+}
+
+// GetTally returns a stored tally of an election
+func (s *VotingContract) GetTally(ctx contractapi.TransactionContextInterface, electionID string, tallyID string) (*VoteTally, error) {
+	if err := checkPermission(ctx, "GetTally", electionID); err != nil {
+		return nil, err
+	}
+
+	return s.getTally(ctx, electionID, tallyID)
+}
+
+// getTally reads a stored tally of an election the caller may see the results of
+func (s *VotingContract) getTally(ctx contractapi.TransactionContextInterface, electionID string, tallyID string) (*VoteTally, error) {
+	if err := s.ensureCanViewElectionResults(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	return getTallyRecord(ctx, electionID, tallyID)
+}
+
+// getTallyRecord reads a stored tally of an election
+func getTallyRecord(ctx contractapi.TransactionContextInterface, electionID string, tallyID string) (*VoteTally, error) {
+	tallyKey, err := ctx.GetStub().CreateCompositeKey(tallyObjectType, []string{electionID, tallyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	tallyJSON, err := ctx.GetStub().GetState(tallyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tallyJSON == nil {
+		return nil, fmt.Errorf("tally %s does not exist for election %s", tallyID, electionID)
+	}
+
+	var tally VoteTally
+	err = json.Unmarshal(tallyJSON, &tally)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tally, nil
+}
+
+// GetLatestTally returns the final tally of an election once there is one, otherwise
+// the most recently stored tally
+func (s *VotingContract) GetLatestTally(ctx contractapi.TransactionContextInterface, electionID string) (*VoteTally, error) {
+	if err := checkPermission(ctx, "GetLatestTally", electionID); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureCanViewElectionResults(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	return getFinalOrLatestTally(ctx, electionID)
+}
+
+// getFinalOrLatestTally returns the final tally of an election, or its latest stored tally
+func getFinalOrLatestTally(ctx contractapi.TransactionContextInterface, electionID string) (*VoteTally, error) {
+	history, err := getTallyHistory(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no tally has been computed for election %s", electionID)
+	}
+
+	for _, tally := range history {
+		if tally.IsFinal {
+			return tally, nil
+		}
+	}
+
+	return history[len(history)-1], nil
+}
+
+// GetTallyHistory returns every stored tally of an election, oldest first
+func (s *VotingContract) GetTallyHistory(ctx contractapi.TransactionContextInterface, electionID string) ([]*VoteTally, error) {
+	if err := checkPermission(ctx, "GetTallyHistory", electionID); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureCanViewElectionResults(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	return getTallyHistory(ctx, electionID)
+}
+
+// getTallyHistory reads the stored tallies of an election ordered by version
+func getTallyHistory(ctx contractapi.TransactionContextInterface, electionID string) ([]*VoteTally, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tallyObjectType, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tallies of election %s: %v", electionID, err)
+	}
+	defer iterator.Close()
+
+	history := []*VoteTally{}
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next tally: %v", err)
+		}
+
+		var tally VoteTally
+		err = json.Unmarshal(queryResult.Value, &tally)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tally: %v", err)
+		}
+		history = append(history, &tally)
+	}
+
+	// Composite keys iterate in tally ID order, the version gives the order of computation
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
@@ -0,0 +1,125 @@
+package chaincode
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// VotingContext is the transaction context of VotingContract. beforeTransaction
+// loads the caller into it once, so getUserId and getCaller do not go back to the
+// certificate and the world state on every role check of a transaction.
+type VotingContext struct {
+	contractapi.TransactionContext
+	function string
+	callerID string
+	caller   *User // nil while the client is not registered
+	loaded   bool
+}
+
+// txRequirements declares what a transaction needs before it runs, on top of the
+// permission matrix the transaction checks itself
+type txRequirements struct {
+	activeCaller bool     // The caller must be registered and not suspended
+	transient    []string // Keys that must be passed in the transient map
+}
+
+// transactionRequirements lists the transactions with requirements, any other
+// transaction accepts any client
+var transactionRequirements = map[string]txRequirements{
+	"InitLedger":             {activeCaller: true},
+	"CreateElection":         {activeCaller: true},
+	"UpdateElection":         {activeCaller: true},
+	"AddCandidate":           {activeCaller: true},
+	"WithdrawCandidate":      {activeCaller: true},
+	"ReorderCandidates":      {activeCaller: true},
+	"UpdateElectionStatus":   {activeCaller: true},
+	"ClearElections":         {activeCaller: true},
+	"CastVote":               {activeCaller: true, transient: []string{ballotTransientKey}},
+	"MigrateVoteIndex":       {activeCaller: true},
+	"ComputeFinalTally":      {activeCaller: true},
+	"SetCertificationPolicy": {activeCaller: true},
+	"CertifyTally":           {activeCaller: true},
+	"RecountElection":        {activeCaller: true},
+	"ResolveTie":             {activeCaller: true},
+	"SetUserRole":            {activeCaller: true},
+	"MigrateVoterIndex":      {activeCaller: true},
+	"UpdateUserStatus":       {activeCaller: true},
+}
+
+// GetTransactionContextHandler makes contractapi pass a VotingContext to every transaction
+func (s *VotingContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(VotingContext)
+}
+
+// GetBeforeTransaction returns the hook contractapi runs before every transaction
+func (s *VotingContract) GetBeforeTransaction() interface{} {
+	return beforeTransaction
+}
+
+// GetUnknownTransaction returns the handler for functions the contract does not define
+func (s *VotingContract) GetUnknownTransaction() interface{} {
+	return unknownTransaction
+}
+
+// beforeTransaction loads the caller, logs the transaction and enforces its requirements
+func beforeTransaction(ctx *VotingContext) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	ctx.function = transactionName(function)
+
+	callerID, caller, err := lookupCaller(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.callerID = callerID
+	ctx.caller = caller
+	ctx.loaded = true
+
+	log.Printf("tx %s: %s called by %s", ctx.GetStub().GetTxID(), ctx.function, callerID)
+
+	requirements := transactionRequirements[ctx.function]
+	if requirements.activeCaller {
+		if caller == nil {
+			return fmt.Errorf("%s requires a registered caller, %s is not registered", ctx.function, callerID)
+		}
+		if caller.Status != "active" {
+			return fmt.Errorf("%s requires an active account, %s is %s", ctx.function, callerID, caller.Status)
+		}
+	}
+
+	if len(requirements.transient) > 0 {
+		transientMap, err := ctx.GetStub().GetTransient()
+		if err != nil {
+			return fmt.Errorf("failed to get transient map: %v", err)
+		}
+		for _, key := range requirements.transient {
+			if _, ok := transientMap[key]; !ok {
+				return fmt.Errorf("%s requires %q in the transient map", ctx.function, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// transactionName returns the name contractapi dispatches a function to: the part after
+// any contract name prefix, with its first rune upper-cased
+func transactionName(function string) string {
+	name := function[strings.LastIndex(function, ":")+1:]
+	first, size := utf8.DecodeRuneInString(name)
+	if size == 0 {
+		return name
+	}
+	return string(unicode.ToUpper(first)) + name[size:]
+}
+
+// unknownTransaction rejects calls to functions the contract does not define
+func unknownTransaction(ctx *VotingContext) error {
+	log.Printf("tx %s: rejected unknown function %s called by %s", ctx.GetStub().GetTxID(), ctx.function, ctx.callerID)
+	return fmt.Errorf("unknown transaction %s", ctx.function)
+}
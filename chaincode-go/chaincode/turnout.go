@@ -0,0 +1,117 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Turnout counters are delta keys, one per vote, but they only say that someone
+// from a governorate voted in a given hour, so they live in the world state
+// where auditors can check them. They are keyed by a counter ID derived from the
+// ballot salt rather than by vote ID, so the world state does not pair a vote record
+// with its voter's governorate.
+
+// turnoutCounterID derives the key suffix of a turnout counter from the secret ballot
+// salt, which keeps it unique per vote without being computable from public records
+func turnoutCounterID(salt string, voteID string) string {
+	counterID := sha256.Sum256([]byte("turnout" + salt + voteID))
+	return hex.EncodeToString(counterID[:])
+}
+
+// putTurnoutCounter records one vote from a governorate in the hour of the tx timestamp
+func putTurnoutCounter(ctx contractapi.TransactionContextInterface, electionID string, governorate string, txTime time.Time, counterID string) error {
+	hour := txTime.UTC().Truncate(time.Hour).Format(time.RFC3339)
+	counterKey, err := ctx.GetStub().CreateCompositeKey(turnoutObjectType, []string{electionID, governorate, hour, counterID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(counterKey, []byte{1})
+	if err != nil {
+		return fmt.Errorf("failed to store turnout counter: %v", err)
+	}
+
+	return nil
+}
+
+// GetTurnout returns the turnout of an election in total, per governorate against
+// the registered voters and per hour
+func (s *VotingContract) GetTurnout(ctx contractapi.TransactionContextInterface, electionID string) (*Turnout, error) {
+	if err := checkPermission(ctx, "GetTurnout", electionID); err != nil {
+		return nil, err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	turnout := Turnout{
+		ElectionID:    electionID,
+		ByGovernorate: make(map[string]GovernorateTurnout),
+		Hourly:        []TurnoutBucket{},
+	}
+
+	registered, err := countRegisteredVoters(ctx, election.EligibleGovernorates)
+	if err != nil {
+		return nil, err
+	}
+	for _, governorate := range election.EligibleGovernorates {
+		turnout.ByGovernorate[governorate] = GovernorateTurnout{RegisteredVoters: registered[governorate]}
+		turnout.RegisteredVoters += registered[governorate]
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(turnoutObjectType, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get turnout counters: %v", err)
+	}
+	defer iterator.Close()
+
+	hourly := make(map[string]int)
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next turnout counter: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		governorate, hour := attributes[1], attributes[2]
+
+		governorateTurnout := turnout.ByGovernorate[governorate]
+		governorateTurnout.Votes++
+		turnout.ByGovernorate[governorate] = governorateTurnout
+		turnout.Votes++
+		hourly[hour]++
+	}
+
+	for governorate, governorateTurnout := range turnout.ByGovernorate {
+		governorateTurnout.Rate = turnoutRate(governorateTurnout.Votes, governorateTurnout.RegisteredVoters)
+		turnout.ByGovernorate[governorate] = governorateTurnout
+	}
+	turnout.Rate = turnoutRate(turnout.Votes, turnout.RegisteredVoters)
+
+	for hour, votes := range hourly {
+		turnout.Hourly = append(turnout.Hourly, TurnoutBucket{Hour: hour, Votes: votes})
+	}
+	sort.Slice(turnout.Hourly, func(i, j int) bool {
+		return turnout.Hourly[i].Hour < turnout.Hourly[j].Hour
+	})
+
+	return &turnout, nil
+}
+
+// turnoutRate returns votes / registered, or 0 when nobody is registered
+func turnoutRate(votes int, registered int) float64 {
+	if registered == 0 {
+		return 0
+	}
+	return float64(votes) / float64(registered)
+}
@@ -0,0 +1,33 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// getTxTime returns the timestamp the client put in the transaction proposal.
+// Every endorsing peer sees the same value, so unlike time.Now() it is safe to
+// write into state or event payloads.
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	if timestamp == nil {
+		return time.Time{}, fmt.Errorf("transaction timestamp is not set")
+	}
+
+	return timestamp.AsTime().UTC(), nil
+}
+
+// getTxTimestamp returns the transaction timestamp formatted as RFC3339
+func getTxTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return txTime.Format(time.RFC3339), nil
+}
@@ -3,33 +3,86 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
-	"time"
+	"slices"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
 
+// electionTransitions is the election lifecycle graph. Each allowed move maps to
+// the roles that may make it. The time-driven moves the scheduler makes only need
+// the commission role, so they do not depend on an admin having been bootstrapped;
+// admins may make them as well.
+var electionTransitions = map[string]map[string][]string{
+	statusScheduled: {
+		statusLive:      {roleCommission, roleAdmin},
+		statusCancelled: {roleCommission},
+	},
+	statusLive: {
+		statusEnded:     {roleCommission, roleAdmin},
+		statusCancelled: {roleCommission},
+	},
+	statusEnded: {
+		statusPublished: {roleCommission, roleAdmin},
+		statusCancelled: {roleCommission},
+	},
+}
+
+// InvalidTransitionError is returned when a status change is not in the election lifecycle graph
+type InvalidTransitionError struct {
+	ElectionID string
+	From       string
+	To         string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid status transition for election %s: %s -> %s", e.ElectionID, e.From, e.To)
+}
+
 // UpdateElectionStatus updates the status of an election
 // This is used by the scheduler and admin processes to manage election lifecycle
 func (s *VotingContract) UpdateElectionStatus(ctx contractapi.TransactionContextInterface, electionID string, newStatus string) error {
-	// Validate the new status
-	validStatuses := map[string]bool{
-		"scheduled": true,
-		"live":      true,
-		"ended":     true,
-		"published": true,
-		"cancelled": true,
+	if err := checkPermission(ctx, "UpdateElectionStatus", electionID); err != nil {
+		return err
 	}
 
-	if !validStatuses[newStatus] {
+	// Validate the new status
+	validStatuses := []string{statusScheduled, statusLive, statusEnded, statusPublished, statusCancelled}
+	if !slices.Contains(validStatuses, newStatus) {
 		return fmt.Errorf("invalid status: %s", newStatus)
 	}
 
 	// Get the current election
-	election, err := s.GetElection(ctx, electionID)
+	election, err := getElection(ctx, electionID)
 	if err != nil {
 		return fmt.Errorf("failed to get election: %v", err)
 	}
 
+	// Check the move is part of the lifecycle graph
+	allowedRoles, ok := electionTransitions[election.Status][newStatus]
+	if !ok {
+		return &InvalidTransitionError{ElectionID: electionID, From: election.Status, To: newStatus}
+	}
+
+	// Check the caller may make this move
+	caller, err := authorize(ctx, fmt.Sprintf("move election %s from %s to %s", electionID, election.Status, newStatus), allowedRoles...)
+	if err != nil {
+		return err
+	}
+
+	// The certification policy is locked when voting opens, so it must be set by then
+	if newStatus == statusLive {
+		if _, err := getCertificationPolicy(ctx, electionID); err != nil {
+			return fmt.Errorf("election %s needs a certification policy before it can go live", electionID)
+		}
+	}
+
+	// Results are published only once the final tally reached its certification quorum
+	if newStatus == statusPublished {
+		if err := ensureCertified(ctx, electionID); err != nil {
+			return err
+		}
+	}
+
 	// Update the status
 	oldStatus := election.Status
 	election.Status = newStatus
@@ -45,12 +98,27 @@ func (s *VotingContract) UpdateElectionStatus(ctx contractapi.TransactionContext
 		return fmt.Errorf("failed to update election: %v", err)
 	}
 
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create event payload
 	eventPayload := map[string]interface{}{
 		"election_id": electionID,
 		"old_status":  oldStatus,
 		"new_status":  newStatus,
-		"timestamp":   time.Now().Format(time.RFC3339),
+		"changed_by":  caller.id,
+		"timestamp":   timestamp,
+	}
+
+	// Voting is closed, so commit the receipt board for independent verification
+	if newStatus == statusEnded {
+		board, err := commitReceiptBoard(ctx, electionID)
+		if err != nil {
+			return err
+		}
+		eventPayload["merkle_root"] = board.MerkleRoot
 	}
 
 	eventPayloadJSON, err := json.Marshal(eventPayload)
@@ -66,50 +134,3 @@ func (s *VotingContract) UpdateElectionStatus(ctx contractapi.TransactionContext
 
 	return nil
 }
-
-// ComputeFinalTally calculates the final tally for an election and marks it as final
-// Used when an election has ended and the final results need to be published
-func (s *VotingContract) ComputeFinalTally(ctx contractapi.TransactionContextInterface, electionID string) (*VoteTally, error) {
-	// Generate a unique ID for this tally
-	tallyID := fmt.Sprintf("final_tally_%s", electionID)
-
-	// Call the existing compute tally function
-	tally, err := s.ComputeVoteTally(ctx, tallyID, electionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to compute vote tally: %v", err)
-	}
-
-	// Mark the tally as final
-	tally.IsFinal = true
-
-	// Re-save the tally with the updated IsFinal flag
-	tallyJSON, err := json.Marshal(tally)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal tally: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(tallyPrefix+tallyID, tallyJSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save final tally: %v", err)
-	}
-
-	// Emit an event for the final tally computation
-	eventPayload := map[string]interface{}{
-		"election_id": electionID,
-		"tally_id":    tallyID,
-		"is_final":    true,
-		"timestamp":   tally.CreatedAt,
-	}
-
-	eventPayloadJSON, err := json.Marshal(eventPayload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal event payload: %v", err)
-	}
-
-	err = ctx.GetStub().SetEvent("final_tally_computed", eventPayloadJSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to emit event: %v", err)
-	}
-
-	return tally, nil
-}
@@ -0,0 +1,130 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// MigrateLegacyUsers re-keys users registered under a bare CN, before user IDs
+// were MSP-qualified, to <governanceMSP>/<CN> and moves their participation records
+// along. Those users were all enrolled through the governance organization's gateway,
+// so the MSP is not a parameter: an admin of another organization must not be able
+// to claim them. It returns how many users were migrated. Existing admins are themselves
+// unreachable until migrated, so it is gated on a governance MSP admin like InitAdmin.
+func (s *VotingContract) MigrateLegacyUsers(ctx contractapi.TransactionContextInterface) (int, error) {
+	isAdmin, err := isGovernanceAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !isAdmin {
+		return 0, fmt.Errorf("only an admin identity of %s can migrate legacy users", governanceMSP)
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(userPrefix, userPrefix+"}")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users: %v", err)
+	}
+	defer iterator.Close()
+
+	migrated := 0
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get next user: %v", err)
+		}
+
+		legacyID := strings.TrimPrefix(queryResult.Key, userPrefix)
+		if strings.Contains(legacyID, "/") {
+			continue
+		}
+
+		var user User
+		err = json.Unmarshal(queryResult.Value, &user)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal user: %v", err)
+		}
+
+		userID := qualifiedUserID(governanceMSP, legacyID)
+		existing, err := ctx.GetStub().GetState(userPrefix + userID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if existing != nil {
+			return 0, fmt.Errorf("cannot migrate %s, user %s already exists", legacyID, userID)
+		}
+
+		user.ID = userID
+		userJSON, err := json.Marshal(user)
+		if err != nil {
+			return 0, err
+		}
+		if err := ctx.GetStub().PutState(userPrefix+userID, userJSON); err != nil {
+			return 0, fmt.Errorf("failed to put user in world state: %v", err)
+		}
+		if err := ctx.GetStub().DelState(queryResult.Key); err != nil {
+			return 0, fmt.Errorf("failed to delete legacy user %s: %v", legacyID, err)
+		}
+		if user.Role == roleVoter {
+			if err := deleteVoterGovernorateIndex(ctx, user.Governorate, legacyID); err != nil {
+				return 0, err
+			}
+			if err := putVoterGovernorateIndex(ctx, user.Governorate, userID); err != nil {
+				return 0, err
+			}
+		}
+
+		for _, electionID := range user.VotedElectionIds {
+			if err := migrateParticipation(ctx, electionID, legacyID, userID); err != nil {
+				return 0, err
+			}
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// migrateParticipation moves a participation record from a legacy voter ID to its
+// MSP-qualified form, so CastVote keeps rejecting a second vote after migration
+func migrateParticipation(ctx contractapi.TransactionContextInterface, electionID string, legacyID string, voterID string) error {
+	legacyKey, err := ctx.GetStub().CreateCompositeKey(participationObjectType, []string{electionID, legacyID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	participationJSON, err := ctx.GetStub().GetState(legacyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if participationJSON == nil {
+		return nil
+	}
+
+	var participation Participation
+	err = json.Unmarshal(participationJSON, &participation)
+	if err != nil {
+		return err
+	}
+	participation.VoterID = voterID
+
+	participationJSON, err = json.Marshal(participation)
+	if err != nil {
+		return err
+	}
+
+	participationKey, err := ctx.GetStub().CreateCompositeKey(participationObjectType, []string{electionID, voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(participationKey, participationJSON); err != nil {
+		return fmt.Errorf("failed to put participation in world state: %v", err)
+	}
+	if err := ctx.GetStub().DelState(legacyKey); err != nil {
+		return fmt.Errorf("failed to delete legacy participation: %v", err)
+	}
+
+	return nil
+}
@@ -3,15 +3,102 @@ package chaincode
 import (
 	"encoding/json"
 	"fmt"
-	"time"
-
 	"slices"
 
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
 
-// RegisterUser registers a new user in the system
+// RegisterUser registers a new user in the system. Admins may register any role and
+// the election commission any role but admin. A client that is not registered yet may
+// only register itself, as a voter, under its own ID.
 func (s *VotingContract) RegisterUser(ctx contractapi.TransactionContextInterface, userId string, governorate string, role string) error {
+	// Validate role
+	validRoles := []string{roleVoter, roleCommission, roleAuditor, roleAdmin}
+	if !slices.Contains(validRoles, role) {
+		return fmt.Errorf("invalid role: %s. Must be one of: %v", role, validRoles)
+	}
+
+	userId, err := qualifyUserID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	callerID, callerUser, err := lookupCaller(ctx)
+	if err != nil {
+		return err
+	}
+	if callerUser == nil {
+		// Self-registration
+		if role != roleVoter {
+			return fmt.Errorf("self-registration is limited to the %s role", roleVoter)
+		}
+		if userId != callerID {
+			return fmt.Errorf("clients can only register themselves, as %s", callerID)
+		}
+	} else {
+		if err := checkPermission(ctx, "RegisterUser", ""); err != nil {
+			return err
+		}
+		caller, err := authorize(ctx, "")
+		if err != nil {
+			return err
+		}
+		if role == roleAdmin && !caller.hasRole(roleAdmin) {
+			return fmt.Errorf("only admins can register admins")
+		}
+	}
+
+	return createUser(ctx, userId, governorate, role, callerID)
+}
+
+// InitAdmin registers the caller as the first admin. It can be called once, by an
+// identity the governance MSP marks as admin through the admin OU or the hf.Type=admin
+// certificate attribute; further admins are registered by existing ones through RegisterUser.
+func (s *VotingContract) InitAdmin(ctx contractapi.TransactionContextInterface, governorate string) error {
+	bootstrapJSON, err := ctx.GetStub().GetState(adminBootstrapKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bootstrapJSON != nil {
+		return fmt.Errorf("the first admin has already been bootstrapped")
+	}
+
+	isAdmin, err := isGovernanceAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return fmt.Errorf("only an admin identity of %s can bootstrap the first admin", governanceMSP)
+	}
+
+	callerID, err := getUserId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	bootstrapJSON, err = json.Marshal(map[string]string{
+		"admin_id":  callerID,
+		"timestamp": timestamp,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(adminBootstrapKey, bootstrapJSON)
+	if err != nil {
+		return fmt.Errorf("failed to record admin bootstrap: %v", err)
+	}
+
+	return createUser(ctx, callerID, governorate, roleAdmin, callerID)
+}
+
+// createUser stores a new user and emits user_registered
+func createUser(ctx contractapi.TransactionContextInterface, userId string, governorate string, role string, registeredBy string) error {
 	// Check if user already exists
 	userJSON, err := ctx.GetStub().GetState(userPrefix + userId)
 	if err != nil {
@@ -21,18 +108,12 @@ func (s *VotingContract) RegisterUser(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("user already exists with ID: %s", userId)
 	}
 
-	// Validate role
-	validRoles := []string{"voter", "election_commission", "auditor"}
-	if !slices.Contains(validRoles, role) {
-		return fmt.Errorf("invalid role: %s. Must be one of: %v", role, validRoles)
-	}
-
 	// Create new user
 	user := User{
 		ID:               userId,
 		Governorate:      governorate,
 		VotedElectionIds: []string{},
-		Role:             role, // Default role
+		Role:             role,
 		Status:           "active",
 	}
 
@@ -46,12 +127,25 @@ func (s *VotingContract) RegisterUser(ctx contractapi.TransactionContextInterfac
 		return err
 	}
 
+	if role == roleVoter {
+		err = putVoterGovernorateIndex(ctx, governorate, userId)
+		if err != nil {
+			return err
+		}
+	}
+
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Emit a user_registered event
 	eventPayload, err := json.Marshal(map[string]string{
-		"user_id":     userId,
-		"governorate": governorate,
-		"role":        role,
-		"timestamp":   time.Now().Format(time.RFC3339),
+		"user_id":       userId,
+		"governorate":   governorate,
+		"role":          role,
+		"registered_by": registeredBy,
+		"timestamp":     timestamp,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal event payload: %v", err)
@@ -67,6 +161,20 @@ func (s *VotingContract) RegisterUser(ctx contractapi.TransactionContextInterfac
 
 // GetUser retrieves a user by ID
 func (s *VotingContract) GetUser(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
+	if err := checkPermission(ctx, "GetUser", ""); err != nil {
+		return nil, err
+	}
+
+	userID, err := qualifyUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return getUser(ctx, userID)
+}
+
+// getUser reads a user by MSP-qualified ID
+func getUser(ctx contractapi.TransactionContextInterface, userID string) (*User, error) {
 	userJSON, err := ctx.GetStub().GetState(userPrefix + userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
@@ -86,6 +194,10 @@ func (s *VotingContract) GetUser(ctx contractapi.TransactionContextInterface, us
 
 // GetAllUsers returns all users in the system
 func (s *VotingContract) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]*User, error) {
+	if err := checkPermission(ctx, "GetAllUsers", ""); err != nil {
+		return nil, err
+	}
+
 	iterator, err := ctx.GetStub().GetStateByRange(userPrefix, userPrefix+"}")
 	if err != nil {
 		return nil, err
@@ -112,8 +224,7 @@ func (s *VotingContract) GetAllUsers(ctx contractapi.TransactionContextInterface
 
 // SetUserRole updates a user's role (admin only)
 func (s *VotingContract) SetUserRole(ctx contractapi.TransactionContextInterface, userID string, role string) error {
-	// Ensure admin privileges
-	if err := s.ensureAdmin(ctx); err != nil {
+	if err := checkPermission(ctx, "SetUserRole", ""); err != nil {
 		return err
 	}
 
@@ -123,6 +234,11 @@ func (s *VotingContract) SetUserRole(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("invalid role: %s. Must be one of: %v", role, validRoles)
 	}
 
+	userID, err := qualifyUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
 	// Get the user
 	userJSON, err := ctx.GetStub().GetState(userPrefix + userID)
 	if err != nil {
@@ -138,7 +254,15 @@ func (s *VotingContract) SetUserRole(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	// Update role
+	// Update role, keeping the voter index in step
+	if user.Role == roleVoter && role != roleVoter {
+		err = deleteVoterGovernorateIndex(ctx, user.Governorate, userID)
+	} else if user.Role != roleVoter && role == roleVoter {
+		err = putVoterGovernorateIndex(ctx, user.Governorate, userID)
+	}
+	if err != nil {
+		return err
+	}
 	user.Role = role
 
 	// Save the updated user
@@ -152,29 +276,13 @@ func (s *VotingContract) SetUserRole(ctx contractapi.TransactionContextInterface
 
 // UpdateUserStatus updates a user's status (active or suspended)
 func (s *VotingContract) UpdateUserStatus(ctx contractapi.TransactionContextInterface, userID string, status string, reason string) error {
-	// Ensure caller has proper privileges (election_commission or auditor)
-	clientID, err := getUserId(ctx)
-	if err != nil {
+	if err := checkPermission(ctx, "UpdateUserStatus", ""); err != nil {
 		return err
 	}
 
-	callerJSON, err := ctx.GetStub().GetState(userPrefix + clientID)
+	callerID, err := getUserId(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read caller from world state: %v", err)
-	}
-	if callerJSON == nil {
-		return fmt.Errorf("caller %s does not exist", clientID)
-	}
-
-	var caller User
-	err = json.Unmarshal(callerJSON, &caller)
-	if err != nil {
-		return err
-	}
-
-	// Check if caller has the right role
-	if caller.Role != "election_commission" && caller.Role != "auditor" {
-		return fmt.Errorf("only election commission or auditor can update user status")
+		return fmt.Errorf("failed to get client identity: %v", err)
 	}
 
 	// Validate status
@@ -183,6 +291,11 @@ func (s *VotingContract) UpdateUserStatus(ctx contractapi.TransactionContextInte
 		return fmt.Errorf("invalid status: %s. Must be one of: %v", status, validStatuses)
 	}
 
+	userID, err = qualifyUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
 	// Get the user
 	userJSON, err := ctx.GetStub().GetState(userPrefix + userID)
 	if err != nil {
@@ -220,13 +333,18 @@ func (s *VotingContract) UpdateUserStatus(ctx contractapi.TransactionContextInte
 		return err
 	}
 
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Emit a user_status_updated event
 	eventPayload, err := json.Marshal(map[string]interface{}{
 		"userId":    userID,
 		"status":    status,
 		"reason":    reason,
-		"updatedBy": clientID,
-		"timestamp": time.Now().Format(time.RFC3339),
+		"updatedBy": callerID,
+		"timestamp": timestamp,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to marshal event payload: %v", err)
@@ -256,11 +374,16 @@ func (s *VotingContract) recordUserRevocation(ctx contractapi.TransactionContext
 		return err
 	}
 
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create revocation record
 	revocation := UserRevocation{
 		UserID:    userID,
 		Reason:    reason,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Timestamp: timestamp,
 		RevokedBy: revokedBy,
 	}
 
@@ -281,31 +404,10 @@ func (s *VotingContract) recordUserRevocation(ctx contractapi.TransactionContext
 
 // GetUserRevocations returns all user revocation records
 func (s *VotingContract) GetUserRevocations(ctx contractapi.TransactionContextInterface) ([]*UserRevocation, error) {
-	// Ensure caller has proper privileges
-	clientID, err := getUserId(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	callerJSON, err := ctx.GetStub().GetState(userPrefix + clientID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read caller from world state: %v", err)
-	}
-	if callerJSON == nil {
-		return nil, fmt.Errorf("caller %s does not exist", clientID)
-	}
-
-	var caller User
-	err = json.Unmarshal(callerJSON, &caller)
-	if err != nil {
+	if err := checkPermission(ctx, "GetUserRevocations", ""); err != nil {
 		return nil, err
 	}
 
-	// Check if caller has the right role
-	if caller.Role != "election_commission" && caller.Role != "auditor" {
-		return nil, fmt.Errorf("only election commission or auditor can view revocations")
-	}
-
 	// Query for revocation records using partial composite key
 	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("revocation", []string{})
 	if err != nil {
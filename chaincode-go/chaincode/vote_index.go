@@ -0,0 +1,166 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// putIndexEntry writes an entry of a composite key index. The key carries all the
+// information, the value only has to be non-empty.
+func putIndexEntry(ctx contractapi.TransactionContextInterface, index string, attributes ...string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to store %s index: %v", index, err)
+	}
+
+	return nil
+}
+
+// indexEntryExists reports whether an entry of a composite key index has been written
+func indexEntryExists(ctx contractapi.TransactionContextInterface, index string, attributes ...string) (bool, error) {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, attributes)
+	if err != nil {
+		return false, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	indexed, err := ctx.GetStub().GetState(indexKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	return indexed != nil, nil
+}
+
+// putVoteElectionIndex writes the vote~election index entry of a vote
+func putVoteElectionIndex(ctx contractapi.TransactionContextInterface, electionID string, voteID string) error {
+	return putIndexEntry(ctx, voteElectionIndex, electionID, voteID)
+}
+
+// getVotesByElection reads the votes of one election through the vote~election index
+func getVotesByElection(ctx contractapi.TransactionContextInterface, electionID string) ([]*Vote, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(voteElectionIndex, []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get votes of election %s: %v", electionID, err)
+	}
+	defer iterator.Close()
+
+	votes := []*Vote{}
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next vote: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+
+		voteJSON, err := ctx.GetStub().GetState(votePrefix + attributes[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if voteJSON == nil {
+			return nil, fmt.Errorf("indexed vote %s does not exist", attributes[1])
+		}
+
+		var vote Vote
+		err = json.Unmarshal(voteJSON, &vote)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, &vote)
+	}
+
+	return votes, nil
+}
+
+// GetVotesByElection returns the votes of an election
+func (s *VotingContract) GetVotesByElection(ctx contractapi.TransactionContextInterface, electionID string) ([]*Vote, error) {
+	if err := checkPermission(ctx, "GetVotesByElection", electionID); err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureCanViewElectionResults(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	return getVotesByElection(ctx, electionID)
+}
+
+// CountVotesByElection returns the number of votes cast in an election
+func (s *VotingContract) CountVotesByElection(ctx contractapi.TransactionContextInterface, electionID string) (int, error) {
+	if err := checkPermission(ctx, "CountVotesByElection", electionID); err != nil {
+		return 0, err
+	}
+
+	if _, err := getElection(ctx, electionID); err != nil {
+		return 0, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(voteElectionIndex, []string{electionID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get votes of election %s: %v", electionID, err)
+	}
+	defer iterator.Close()
+
+	count := 0
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			return 0, fmt.Errorf("failed to get next vote: %v", err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// MigrateVoteIndex adds the vote~election index entry for votes stored before
+// the index existed and returns how many were added
+func (s *VotingContract) MigrateVoteIndex(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := checkPermission(ctx, "MigrateVoteIndex", ""); err != nil {
+		return 0, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(votePrefix, votePrefix+"}")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get votes: %v", err)
+	}
+	defer iterator.Close()
+
+	migrated := 0
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get next vote: %v", err)
+		}
+
+		var vote Vote
+		err = json.Unmarshal(queryResult.Value, &vote)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal vote: %v", err)
+		}
+
+		indexed, err := indexEntryExists(ctx, voteElectionIndex, vote.ElectionID, vote.VoteID)
+		if err != nil {
+			return 0, err
+		}
+		if indexed {
+			continue
+		}
+
+		if err := putVoteElectionIndex(ctx, vote.ElectionID, vote.VoteID); err != nil {
+			return 0, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
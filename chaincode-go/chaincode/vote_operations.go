@@ -1,6 +1,7 @@
 package chaincode
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -11,126 +12,350 @@ import (
 	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
 )
 
-// CastVote allows a voter to cast a vote
-func (s *VotingContract) CastVote(ctx contractapi.TransactionContextInterface, voteID string, electionID string, candidateID string) (string, error) {
+// CastVote casts the BallotInput passed under the "ballot" transient key, with the transaction ID as vote ID
+func (s *VotingContract) CastVote(ctx contractapi.TransactionContextInterface, electionID string) (*VoteReceipt, error) {
+	if err := checkPermission(ctx, "CastVote", electionID); err != nil {
+		return nil, err
+	}
+
+	ballotInput, err := getBallotInput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidateID := ballotInput.CandidateID
+
 	// Check if the election is active
 	electionJSON, err := ctx.GetStub().GetState(electionPrefix + electionID)
 	if err != nil {
-		return "", fmt.Errorf("failed to read from world state: %v", err)
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	if electionJSON == nil {
-		return "", fmt.Errorf("the election %s does not exist", electionID)
+		return nil, fmt.Errorf("the election %s does not exist", electionID)
 	}
 
 	var election Election
 	err = json.Unmarshal(electionJSON, &election)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if election.Status != "live" {
-		return "", fmt.Errorf("the election %s is not live", electionID)
+		return nil, fmt.Errorf("the election %s is not live", electionID)
 	}
 
-	// Get voter ID by extracting CN from client identity
-	voterId, err := getUserId(ctx)
+	// The ledger, not the off-chain scheduler, decides whether voting is open
+	startTime, endTime, err := parseVotingWindow(&election)
 	if err != nil {
-		return "", fmt.Errorf("failed to get voter ID: %v", err)
+		return nil, err
 	}
-
-	// Retrieve user record
-	userJSON, err := ctx.GetStub().GetState(userPrefix + voterId)
+	txTime, err := getTxTime(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to read user from world state: %v", err)
+		return nil, err
+	}
+	if txTime.Before(startTime) {
+		return nil, fmt.Errorf("voting for election %s opens at %s", electionID, election.StartTime)
 	}
-	if userJSON == nil {
-		return "", fmt.Errorf("user %s is not registered in the system", voterId)
+	if !txTime.Before(endTime) {
+		return nil, fmt.Errorf("voting for election %s closed at %s", electionID, election.EndTime)
 	}
 
-	var user User
-	err = json.Unmarshal(userJSON, &user)
+	// Get the MSP-qualified voter ID of the client and their user record
+	voterId, user, err := lookupCaller(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %s is not registered in the system", voterId)
 	}
 
 	// Check if user is active
 	if user.Status != "active" {
-		return "", fmt.Errorf("user account is not active")
+		return nil, fmt.Errorf("user account is not active")
 	}
 
 	// Check if user has already voted in this election
-	if slices.Contains(user.VotedElectionIds, electionID) {
-		return "", fmt.Errorf("user has already voted in this election")
+	participationKey, err := ctx.GetStub().CreateCompositeKey(participationObjectType, []string{electionID, voterId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	participationJSON, err := ctx.GetStub().GetState(participationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read participation from world state: %v", err)
+	}
+	if participationJSON != nil {
+		return nil, fmt.Errorf("user has already voted in this election")
 	}
 
 	// Check if user's governorate is eligible for this election
 	if !slices.Contains(election.EligibleGovernorates, user.Governorate) {
-		return "", fmt.Errorf("user from %s is not eligible to vote in this election", user.Governorate)
+		return nil, fmt.Errorf("user from %s is not eligible to vote in this election", user.Governorate)
 	}
 
 	// Check if candidate is valid for this election
-	isValidCandidate := false
-	for _, candidate := range election.Candidates {
-		if candidate.CandidateID == candidateID {
-			isValidCandidate = true
-			break
-		}
+	candidateIndex := slices.IndexFunc(election.Candidates, func(c Candidate) bool {
+		return c.CandidateID == candidateID
+	})
+	if candidateIndex == -1 {
+		return nil, fmt.Errorf("invalid candidate ID: %s", candidateID)
+	}
+	if election.Candidates[candidateIndex].Withdrawn {
+		return nil, fmt.Errorf("candidate %s has withdrawn from election %s", candidateID, electionID)
+	}
+
+	// Derive the vote ID from the transaction and refuse to overwrite an existing ballot
+	voteID := ctx.GetStub().GetTxID()
+	existingVoteJSON, err := ctx.GetStub().GetState(votePrefix + voteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
-	if !isValidCandidate {
-		return "", fmt.Errorf("invalid candidate ID: %s", candidateID)
+	if existingVoteJSON != nil {
+		return nil, fmt.Errorf("vote %s already exists", voteID)
 	}
 
-	// Create the vote receipt
-	receipt := sha256.Sum256([]byte(voteID + electionID + candidateID))
+	// Salt the receipt with the voter's secret nonce so it cannot be matched to a candidate
+	receipt := sha256.Sum256([]byte(ballotInput.Nonce + voteID))
 	receiptHex := hex.EncodeToString(receipt[:])
+	receiptKey, err := ctx.GetStub().CreateCompositeKey(receiptObjectType, []string{electionID, receiptHex})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
 
-	vote := Vote{
+	// The candidate only goes to the private collection.
+	// The governorate is captured now because the voter may move after voting.
+	privateBallot := PrivateBallot{
 		VoteID:      voteID,
-		VoterID:     voterId,
 		ElectionID:  electionID,
 		CandidateID: candidateID,
-		Receipt:     receiptHex,
-		CreatedAt:   time.Now().Format(time.RFC3339),
+		Governorate: user.Governorate,
+		Salt:        ballotInput.Salt,
+	}
+	privateBallotJSON, err := json.Marshal(privateBallot)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ballot and the participation record share no field, so world state queries
+	// cannot join them; the block of this transaction still can
+	vote := Vote{
+		VoteID:     voteID,
+		ElectionID: electionID,
+		Receipt:    receiptHex,
 	}
 	voteJSON, err := json.Marshal(vote)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	participation := Participation{
+		VoterID:    voterId,
+		ElectionID: electionID,
+		CreatedAt:  txTime.Format(time.RFC3339),
+	}
+	participationJSON, err = json.Marshal(participation)
+	if err != nil {
+		return nil, err
 	}
 
 	// Update user's voting history
 	user.VotedElectionIds = append(user.VotedElectionIds, electionID)
 	updatedUserJSON, err := json.Marshal(user)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	// Store the ballot, the participation record and the updated user record
+	err = putBallot(ctx, electionID, voteID, privateBallotJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	err = putTurnoutCounter(ctx, electionID, user.Governorate, txTime, turnoutCounterID(ballotInput.Salt, voteID))
+	if err != nil {
+		return nil, err
 	}
 
-	// Store both the vote and updated user record
 	err = ctx.GetStub().PutState(votePrefix+voteID, voteJSON)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutState(participationKey, participationJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutState(receiptKey, []byte(voteID))
+	if err != nil {
+		return nil, err
 	}
 
-	// Emit a vote_cast event with the entire vote object
-	// This will eliminate the need to fetch the vote again in the client
-	eventJSON, err := json.Marshal(vote)
+	err = putVoteElectionIndex(ctx, electionID, voteID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	err = ctx.GetStub().SetEvent("vote_cast", eventJSON)
+	// Emit a vote_cast event with the public ballot only, never the voter or candidate
+	err = ctx.GetStub().SetEvent("vote_cast", voteJSON)
 	if err != nil {
-		return "", fmt.Errorf("failed to emit vote_cast event: %v", err)
+		return nil, fmt.Errorf("failed to emit vote_cast event: %v", err)
 	}
 
 	err = ctx.GetStub().PutState(userPrefix+voterId, updatedUserJSON)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return &VoteReceipt{VoteID: voteID, Receipt: vote.Receipt}, nil
+}
+
+// getBallotInput reads the voter's choice from the transient map
+func getBallotInput(ctx contractapi.TransactionContextInterface) (*BallotInput, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient map: %v", err)
+	}
+
+	ballotJSON, ok := transientMap[ballotTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("%s must be passed in the transient map", ballotTransientKey)
+	}
+
+	var ballotInput BallotInput
+	err = json.Unmarshal(ballotJSON, &ballotInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ballot input: %v", err)
+	}
+	if ballotInput.CandidateID == "" {
+		return nil, fmt.Errorf("candidate_id is required in the ballot input")
+	}
+	if len(ballotInput.Nonce) < minNonceLength {
+		return nil, fmt.Errorf("nonce must be at least %d characters", minNonceLength)
+	}
+	if len(ballotInput.Salt) < minSaltLength {
+		return nil, fmt.Errorf("salt must be at least %d characters", minSaltLength)
+	}
+
+	return &ballotInput, nil
+}
+
+// VerifyReceipt confirms that a receipt belongs to a ballot recorded and counted in an election.
+// Voters can recompute the receipt as sha256(nonce + voteID) to check it is their own.
+func (s *VotingContract) VerifyReceipt(ctx contractapi.TransactionContextInterface, electionID string, receipt string) (*ReceiptVerification, error) {
+	if err := checkPermission(ctx, "VerifyReceipt", electionID); err != nil {
+		return nil, err
+	}
+
+	if _, err := getElection(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	verification := ReceiptVerification{
+		ElectionID: electionID,
+		Receipt:    receipt,
+	}
+
+	receiptKey, err := ctx.GetStub().CreateCompositeKey(receiptObjectType, []string{electionID, receipt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	voteID, err := ctx.GetStub().GetState(receiptKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if voteID == nil {
+		return &verification, nil
+	}
+
+	vote, err := getVote(ctx, string(voteID))
+	if err != nil {
+		return nil, err
+	}
+	verification.Recorded = vote.Receipt == receipt
+
+	// Fabric checks private data against the hash in the block when committing it and
+	// tallies count every committed ballot, so a ballot hash on the ledger means it was counted
+	key, err := ballotKey(ctx, vote.ElectionID, vote.VoteID)
+	if err != nil {
+		return nil, err
+	}
+	privateHash, err := ctx.GetStub().GetPrivateDataHash(ballotCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private ballot hash %s: %v", vote.VoteID, err)
+	}
+	verification.Counted = verification.Recorded && privateHash != nil
+
+	return &verification, nil
+}
+
+// VerifyBallotHashes checks that the private ballot of every vote in an election matches
+// the hash of it on the ledger. It reads the ballots, so it must run on a collection member peer.
+func (s *VotingContract) VerifyBallotHashes(ctx contractapi.TransactionContextInterface, electionID string) (*BallotHashVerification, error) {
+	if err := checkPermission(ctx, "VerifyBallotHashes", electionID); err != nil {
+		return nil, err
+	}
+
+	if _, err := getElection(ctx, electionID); err != nil {
+		return nil, err
+	}
+
+	votes, err := getVotesByElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := BallotHashVerification{
+		ElectionID: electionID,
+		Mismatched: []string{},
+		Missing:    []string{},
+	}
+	for _, vote := range votes {
+		key, err := ballotKey(ctx, electionID, vote.VoteID)
+		if err != nil {
+			return nil, err
+		}
+		ledgerHash, err := ctx.GetStub().GetPrivateDataHash(ballotCollection, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private ballot hash %s: %v", vote.VoteID, err)
+		}
+		privateBallotJSON, err := ctx.GetStub().GetPrivateData(ballotCollection, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private ballot %s: %v", vote.VoteID, err)
+		}
+
+		verification.Checked++
+		if ledgerHash == nil || privateBallotJSON == nil {
+			verification.Missing = append(verification.Missing, vote.VoteID)
+			continue
+		}
+		ballotHash := sha256.Sum256(privateBallotJSON)
+		if !bytes.Equal(ballotHash[:], ledgerHash) {
+			verification.Mismatched = append(verification.Mismatched, vote.VoteID)
+		}
 	}
-	return vote.Receipt, nil
+	verification.Valid = len(verification.Mismatched) == 0 && len(verification.Missing) == 0
+
+	return &verification, nil
 }
 
-// GetVote returns the vote stored in the world state with given voteID
+// GetVote returns the ballot stored in the world state with given voteID
 func (s *VotingContract) GetVote(ctx contractapi.TransactionContextInterface, voteID string) (*Vote, error) {
+	if err := checkPermission(ctx, "GetVote", ""); err != nil {
+		return nil, err
+	}
+
+	vote, err := getVote(ctx, voteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureCanViewElectionResults(ctx, vote.ElectionID); err != nil {
+		return nil, err
+	}
+
+	return vote, nil
+}
+
+// getVote reads a vote from the world state without checking the results embargo
+func getVote(ctx contractapi.TransactionContextInterface, voteID string) (*Vote, error) {
 	voteJSON, err := ctx.GetStub().GetState(votePrefix + voteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
@@ -148,8 +373,65 @@ func (s *VotingContract) GetVote(ctx contractapi.TransactionContextInterface, vo
 	return &vote, nil
 }
 
-// GetAllVotes returns all votes found in world state
+// GetParticipation returns the participation record of a voter in an election
+func (s *VotingContract) GetParticipation(ctx contractapi.TransactionContextInterface, electionID string, voterID string) (*Participation, error) {
+	if err := checkPermission(ctx, "GetParticipation", electionID); err != nil {
+		return nil, err
+	}
+
+	voterID, err := qualifyUserID(ctx, voterID)
+	if err != nil {
+		return nil, err
+	}
+
+	participationKey, err := ctx.GetStub().CreateCompositeKey(participationObjectType, []string{electionID, voterID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	participationJSON, err := ctx.GetStub().GetState(participationKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if participationJSON == nil {
+		return nil, fmt.Errorf("voter %s has not voted in election %s", voterID, electionID)
+	}
+
+	var participation Participation
+	err = json.Unmarshal(participationJSON, &participation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &participation, nil
+}
+
+// countParticipation returns how many voters took part in an election
+func countParticipation(ctx contractapi.TransactionContextInterface, electionID string) (int, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(participationObjectType, []string{electionID})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get participation records: %v", err)
+	}
+	defer iterator.Close()
+
+	count := 0
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			return 0, fmt.Errorf("failed to get next participation record: %v", err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// GetAllVotes returns the votes found in world state, leaving out elections whose
+// results the caller may not see yet
 func (s *VotingContract) GetAllVotes(ctx contractapi.TransactionContextInterface) ([]*Vote, error) {
+	if err := checkPermission(ctx, "GetAllVotes", ""); err != nil {
+		return nil, err
+	}
+
 	iterator, err := ctx.GetStub().GetStateByRange(votePrefix, votePrefix+"}")
 	if err != nil {
 		return nil, err
@@ -157,6 +439,7 @@ func (s *VotingContract) GetAllVotes(ctx contractapi.TransactionContextInterface
 	defer iterator.Close()
 
 	var votes []*Vote
+	visible := make(map[string]bool)
 	for iterator.HasNext() {
 		queryResponse, err := iterator.Next()
 		if err != nil {
@@ -168,6 +451,22 @@ func (s *VotingContract) GetAllVotes(ctx contractapi.TransactionContextInterface
 		if err != nil {
 			return nil, err
 		}
+
+		allowed, ok := visible[vote.ElectionID]
+		if !ok {
+			election, err := getElection(ctx, vote.ElectionID)
+			if err != nil {
+				return nil, err
+			}
+			allowed, _, err = canViewResults(ctx, election)
+			if err != nil {
+				return nil, err
+			}
+			visible[vote.ElectionID] = allowed
+		}
+		if !allowed {
+			continue
+		}
 		votes = append(votes, &vote)
 	}
 
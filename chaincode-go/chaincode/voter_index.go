@@ -0,0 +1,101 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+)
+
+// Registered voters are indexed per governorate so turnout can count them without
+// reading every user. The index is kept in step wherever a user becomes or stops
+// being a voter.
+
+// putVoterGovernorateIndex writes the voter~governorate index entry of a voter
+func putVoterGovernorateIndex(ctx contractapi.TransactionContextInterface, governorate string, userID string) error {
+	return putIndexEntry(ctx, voterGovernorateIndex, governorate, userID)
+}
+
+// deleteVoterGovernorateIndex removes the voter~governorate index entry of a voter
+func deleteVoterGovernorateIndex(ctx contractapi.TransactionContextInterface, governorate string, userID string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(voterGovernorateIndex, []string{governorate, userID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(indexKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete voter index: %v", err)
+	}
+
+	return nil
+}
+
+// countRegisteredVoters returns the number of registered voters of each governorate
+// given, read from the voter~governorate index
+func countRegisteredVoters(ctx contractapi.TransactionContextInterface, governorates []string) (map[string]int, error) {
+	registered := make(map[string]int)
+	for _, governorate := range governorates {
+		iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(voterGovernorateIndex, []string{governorate})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get voters of %s: %v", governorate, err)
+		}
+
+		for iterator.HasNext() {
+			if _, err := iterator.Next(); err != nil {
+				iterator.Close()
+				return nil, fmt.Errorf("failed to get next voter: %v", err)
+			}
+			registered[governorate]++
+		}
+		iterator.Close()
+	}
+
+	return registered, nil
+}
+
+// MigrateVoterIndex adds the voter~governorate index entry for voters registered
+// before the index existed and returns how many were added
+func (s *VotingContract) MigrateVoterIndex(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := checkPermission(ctx, "MigrateVoterIndex", ""); err != nil {
+		return 0, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(userPrefix, userPrefix+"}")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get users: %v", err)
+	}
+	defer iterator.Close()
+
+	migrated := 0
+	for iterator.HasNext() {
+		queryResult, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get next user: %v", err)
+		}
+
+		var user User
+		err = json.Unmarshal(queryResult.Value, &user)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal user: %v", err)
+		}
+		if user.Role != roleVoter {
+			continue
+		}
+
+		indexed, err := indexEntryExists(ctx, voterGovernorateIndex, user.Governorate, user.ID)
+		if err != nil {
+			return 0, err
+		}
+		if indexed {
+			continue
+		}
+
+		if err := putVoterGovernorateIndex(ctx, user.Governorate, user.ID); err != nil {
+			return 0, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -14,6 +14,10 @@ type VotingContract struct {
 
 // // GetWorldState returns all key-value pairs in world state (for debugging)
 func (s *VotingContract) GetWorldState(ctx contractapi.TransactionContextInterface) (map[string]any, error) {
+	if err := checkPermission(ctx, "GetWorldState", ""); err != nil {
+		return nil, err
+	}
+
 	iterator, err := ctx.GetStub().GetStateByRange("", "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get world state: %v", err)
@@ -41,6 +45,10 @@ func (s *VotingContract) GetWorldState(ctx contractapi.TransactionContextInterfa
 
 // InitLedger initializes the ledger with some sample elections
 func (s *VotingContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	if err := checkPermission(ctx, "InitLedger", ""); err != nil {
+		return err
+	}
+
 	elections := []Election{
 		{
 			ElectionID:           "election1",
@@ -55,6 +63,7 @@ func (s *VotingContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	}
 
 	for _, election := range elections {
+		election.LotsSeed = newLotsSeed(ctx)
 		electionJSON, err := json.Marshal(election)
 		if err != nil {
 			return err
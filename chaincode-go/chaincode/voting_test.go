@@ -0,0 +1,1690 @@
+package chaincode_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/v2/shim"
+	"github.com/hyperledger/fabric-contract-api-go/v2/contractapi"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go-apiv2/msp"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testLedger backs the counterfeiter stub with an in-memory world state so the
+// voting transactions can be exercised end to end
+type testLedger struct {
+	state   map[string][]byte
+	private map[string]map[string][]byte
+	stub    *mocks.ChaincodeStub
+}
+
+var testTxTime = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+const (
+	testNonce = "voter-secret-nonce"
+	testSalt  = "0123456789abcdef0123456789abcdef"
+)
+
+func newTestLedger() *testLedger {
+	ledger := &testLedger{
+		state:   make(map[string][]byte),
+		private: make(map[string]map[string][]byte),
+		stub:    &mocks.ChaincodeStub{},
+	}
+
+	ledger.stub.GetStateStub = func(key string) ([]byte, error) {
+		return ledger.state[key], nil
+	}
+	ledger.stub.PutStateStub = func(key string, value []byte) error {
+		ledger.state[key] = value
+		return nil
+	}
+	ledger.stub.DelStateStub = func(key string) error {
+		delete(ledger.state, key)
+		return nil
+	}
+	ledger.stub.GetStateByRangeStub = func(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+		// Like the peer, an empty start key skips the composite key namespace
+		if startKey == "" {
+			startKey = "\x01"
+		}
+		return ledger.iterator(startKey, endKey), nil
+	}
+	ledger.stub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	ledger.stub.SplitCompositeKeyStub = new(shim.ChaincodeStub).SplitCompositeKey
+	ledger.stub.GetStateByPartialCompositeKeyStub = func(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		return ledger.iterator(prefix, prefix+string(utf8.MaxRune)), nil
+	}
+	ledger.stub.PutPrivateDataStub = func(collection string, key string, value []byte) error {
+		if ledger.private[collection] == nil {
+			ledger.private[collection] = make(map[string][]byte)
+		}
+		ledger.private[collection][key] = value
+		return nil
+	}
+	ledger.stub.GetPrivateDataStub = func(collection string, key string) ([]byte, error) {
+		return ledger.private[collection][key], nil
+	}
+	ledger.stub.GetPrivateDataByPartialCompositeKeyStub = func(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		return newIterator(ledger.private[collection], prefix, prefix+string(utf8.MaxRune)), nil
+	}
+	ledger.stub.GetPrivateDataHashStub = func(collection string, key string) ([]byte, error) {
+		value, ok := ledger.private[collection][key]
+		if !ok {
+			return nil, nil
+		}
+		hash := sha256.Sum256(value)
+		return hash[:], nil
+	}
+	ledger.stub.GetTxTimestampReturns(timestamppb.New(testTxTime), nil)
+	ledger.stub.GetTxIDReturns("tx1")
+
+	return ledger
+}
+
+// iterator returns the world state keys in [startKey, endKey) in sorted order
+func (l *testLedger) iterator(startKey string, endKey string) *mocks.StateQueryIterator {
+	return newIterator(l.state, startKey, endKey)
+}
+
+func newIterator(state map[string][]byte, startKey string, endKey string) *mocks.StateQueryIterator {
+	var keys []string
+	for key := range state {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextStub = func() bool {
+		return len(keys) > 0
+	}
+	iterator.NextStub = func() (*queryresult.KV, error) {
+		key := keys[0]
+		keys = keys[1:]
+		return &queryresult.KV{Key: key, Value: state[key]}, nil
+	}
+
+	return iterator
+}
+
+// contextFor returns a transaction context submitted by the client with the given CN
+func (l *testLedger) contextFor(mspID string, cn string) *mocks.TransactionContext {
+	identity := &mocks.ClientIdentity{}
+	rawID := fmt.Sprintf("x509::CN=%s,OU=client,O=Hyperledger,ST=North Carolina,C=US::CN=ca.example.com,O=example.com", cn)
+	identity.GetIDReturns(base64.StdEncoding.EncodeToString([]byte(rawID)), nil)
+	identity.GetMSPIDReturns(mspID, nil)
+	identity.GetX509CertificateReturns(&x509.Certificate{Subject: pkix.Name{CommonName: cn}}, nil)
+
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(l.stub)
+	transactionContext.GetClientIdentityReturns(identity)
+
+	return transactionContext
+}
+
+// invoke runs a transaction through the chaincode, like a peer does, so the
+// transaction context and hooks of VotingContract are in play
+func (l *testLedger) invoke(t *testing.T, mspID string, cn string, function string, args ...string) *peer.Response {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn, OrganizationalUnit: []string{"client"}},
+		NotBefore:    testTxTime.Add(-time.Hour),
+		NotAfter:     testTxTime.Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+	})
+	require.NoError(t, err)
+	l.stub.GetCreatorReturns(creator, nil)
+	l.stub.GetFunctionAndParametersReturns(function, args)
+
+	cc, err := contractapi.NewChaincode(&chaincode.VotingContract{})
+	require.NoError(t, err)
+	return cc.Invoke(l.stub)
+}
+
+// castVote submits CastVote with the candidate passed through the transient map
+func (l *testLedger) castVote(ctx *mocks.TransactionContext, electionID string, candidateID string) (*chaincode.VoteReceipt, error) {
+	ballotJSON, err := json.Marshal(chaincode.BallotInput{CandidateID: candidateID, Nonce: testNonce, Salt: testSalt})
+	if err != nil {
+		return nil, err
+	}
+	l.stub.GetTransientReturns(map[string][]byte{"ballot": ballotJSON}, nil)
+
+	voting := chaincode.VotingContract{}
+	return voting.CastVote(ctx, electionID)
+}
+
+func (l *testLedger) privateBallot(t *testing.T, electionID string, voteID string) []byte {
+	return l.private["ballotCollection"][ballotKey(t, electionID, voteID)]
+}
+
+func ballotKey(t *testing.T, electionID string, voteID string) string {
+	key, err := shim.CreateCompositeKey("ballot", []string{electionID, voteID})
+	require.NoError(t, err)
+	return key
+}
+
+func (l *testLedger) putJSON(t *testing.T, key string, value any) {
+	valueJSON, err := json.Marshal(value)
+	require.NoError(t, err)
+	l.state[key] = valueJSON
+}
+
+func (l *testLedger) getJSON(t *testing.T, key string, value any) {
+	valueJSON, ok := l.state[key]
+	require.True(t, ok, "key %s not found in world state", key)
+	require.NoError(t, json.Unmarshal(valueJSON, value))
+}
+
+// lastEvent returns the payload of the most recent event with the given name
+func (l *testLedger) lastEvent(t *testing.T, name string) map[string]any {
+	for i := l.stub.SetEventCallCount() - 1; i >= 0; i-- {
+		eventName, payload := l.stub.SetEventArgsForCall(i)
+		if eventName == name {
+			var event map[string]any
+			require.NoError(t, json.Unmarshal(payload, &event))
+			return event
+		}
+	}
+	require.Failf(t, "event not emitted", "no %s event", name)
+	return nil
+}
+
+func seedLiveElection(t *testing.T, ledger *testLedger) {
+	ledger.putJSON(t, "election_election1", chaincode.Election{
+		ElectionID:           "election1",
+		Name:                 "Presidential Election 2024",
+		Candidates:           []chaincode.Candidate{{CandidateID: "candidate1"}, {CandidateID: "candidate2"}},
+		StartTime:            "2024-01-01T00:00:00Z",
+		EndTime:              "2024-01-31T23:59:59Z",
+		EligibleGovernorates: []string{"Cairo"},
+		Status:               "live",
+	})
+}
+
+// setElectionStatus moves an election to a status without going through the lifecycle
+func setElectionStatus(t *testing.T, ledger *testLedger, electionID string, status string) {
+	var election chaincode.Election
+	ledger.getJSON(t, "election_"+electionID, &election)
+	election.Status = status
+	ledger.putJSON(t, "election_"+electionID, election)
+}
+
+// seedUser registers an Org1MSP user with the given CN
+func seedUser(t *testing.T, ledger *testLedger, cn string, role string) {
+	userID := "Org1MSP/" + cn
+	ledger.putJSON(t, "user_"+userID, chaincode.User{
+		ID:               userID,
+		Governorate:      "Cairo",
+		VotedElectionIds: []string{},
+		Role:             role,
+		Status:           "active",
+	})
+}
+
+func TestCastVoteUsesTxTimestamp(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+
+	voting := chaincode.VotingContract{}
+	voter := ledger.contextFor("Org1MSP", "voter1")
+	_, err := ledger.castVote(voter, "election1", "candidate1")
+	require.NoError(t, err)
+
+	participation, err := voting.GetParticipation(voter, "election1", "voter1")
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-15T10:30:00Z", participation.CreatedAt)
+}
+
+func TestRegisterUserUsesTxTimestamp(t *testing.T) {
+	ledger := newTestLedger()
+	voting := chaincode.VotingContract{}
+
+	ledger.stub.GetTxTimestampReturns(timestamppb.New(testTxTime.Add(time.Hour)), nil)
+	err := voting.RegisterUser(ledger.contextFor("Org1MSP", "voter1"), "voter1", "Cairo", "voter")
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-15T11:30:00Z", ledger.lastEvent(t, "user_registered")["timestamp"])
+
+	ledger.stub.GetTxTimestampReturns(nil, fmt.Errorf("no header"))
+	err = voting.RegisterUser(ledger.contextFor("Org1MSP", "voter2"), "voter2", "Cairo", "voter")
+	require.EqualError(t, err, "failed to get transaction timestamp: no header")
+}
+
+func TestUpdateUserStatusUsesTxTimestamp(t *testing.T) {
+	ledger := newTestLedger()
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "commissioner", "election_commission")
+
+	voting := chaincode.VotingContract{}
+	err := voting.UpdateUserStatus(ledger.contextFor("Org1MSP", "commissioner"), "voter1", "suspended", "fraud")
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-15T10:30:00Z", ledger.lastEvent(t, "user_status_updated")["timestamp"])
+
+	revocationKey, err := shim.CreateCompositeKey("revocation", []string{"Org1MSP/voter1"})
+	require.NoError(t, err)
+	var revocation chaincode.UserRevocation
+	ledger.getJSON(t, revocationKey, &revocation)
+	require.Equal(t, "2024-01-15T10:30:00Z", revocation.Timestamp)
+}
+
+func TestUpdateElectionStatusTransitions(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "admin", "admin")
+	seedUser(t, ledger, "auditor1", "auditor")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	// Voting only opens once the certification policy is set
+	voting := chaincode.VotingContract{}
+	setElectionStatus(t, ledger, "election1", "scheduled")
+	err := voting.UpdateElectionStatus(commission, "election1", "live")
+	require.EqualError(t, err, "election election1 needs a certification policy before it can go live")
+	err = voting.SetCertificationPolicy(commission, "election1", 2, `["commissioner","auditor1"]`)
+	require.NoError(t, err)
+
+	// The scheduler's time-driven moves only need a commission identity
+	err = voting.UpdateElectionStatus(commission, "election1", "live")
+	require.NoError(t, err)
+
+	err = voting.UpdateElectionStatus(ledger.contextFor("Org1MSP", "voter1"), "election1", "ended")
+	require.EqualError(t, err, "role voter is not allowed to call UpdateElectionStatus")
+	err = voting.UpdateElectionStatus(ledger.contextFor("Org1MSP", "admin"), "election1", "cancelled")
+	require.EqualError(t, err, "role admin is not allowed to move election election1 from live to cancelled")
+
+	err = voting.UpdateElectionStatus(commission, "election1", "scheduled")
+	var transitionErr *chaincode.InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	require.Equal(t, "live", transitionErr.From)
+	require.Equal(t, "scheduled", transitionErr.To)
+
+	err = voting.UpdateElectionStatus(commission, "election1", "ended")
+	require.NoError(t, err)
+	event := ledger.lastEvent(t, "election_status_changed")
+	require.Equal(t, "Org1MSP/commissioner", event["changed_by"])
+	require.Equal(t, "live", event["old_status"])
+
+	certifyFinalTally(t, ledger, "election1", "commissioner", "auditor1")
+	err = voting.UpdateElectionStatus(commission, "election1", "published")
+	require.NoError(t, err)
+
+	err = voting.UpdateElectionStatus(commission, "election1", "cancelled")
+	require.EqualError(t, err, "invalid status transition for election election1: published -> cancelled")
+}
+
+func TestCastVoteOutsideVotingWindow(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+
+	ledger.stub.GetTxTimestampReturns(timestamppb.New(time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC)), nil)
+	_, err := ledger.castVote(voter, "election1", "candidate1")
+	require.EqualError(t, err, "voting for election election1 opens at 2024-01-01T00:00:00Z")
+
+	// The scheduler has not ended the election yet, but the ledger still refuses
+	ledger.stub.GetTxTimestampReturns(timestamppb.New(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)), nil)
+	_, err = ledger.castVote(voter, "election1", "candidate1")
+	require.EqualError(t, err, "voting for election election1 closed at 2024-01-31T23:59:59Z")
+	require.NotContains(t, ledger.state, "vote_tx1")
+}
+
+func TestUpdateElection(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	voting := chaincode.VotingContract{}
+	err := voting.UpdateElection(commission, "election1", `{"name":"Renamed"}`)
+	require.EqualError(t, err, "UpdateElection is not allowed while election election1 is live")
+
+	var election chaincode.Election
+	ledger.getJSON(t, "election_election1", &election)
+	election.Status = "scheduled"
+	ledger.putJSON(t, "election_election1", election)
+
+	err = voting.UpdateElection(commission, "election1", `{"status":"live"}`)
+	require.ErrorContains(t, err, "unknown field")
+
+	err = voting.UpdateElection(commission, "election1", `{"end_time":"2023-12-01T00:00:00Z"}`)
+	require.EqualError(t, err, "end time 2023-12-01T00:00:00Z must be after start time 2024-01-01T00:00:00Z")
+
+	err = voting.UpdateElection(commission, "election1", `{"name":"Renamed","description":""}`)
+	require.NoError(t, err)
+	ledger.getJSON(t, "election_election1", &election)
+	require.Equal(t, "Renamed", election.Name)
+
+	event := ledger.lastEvent(t, "election_updated")
+	require.Equal(t, "Org1MSP/commissioner", event["updated_by"])
+	require.Equal(t, map[string]any{
+		"name": map[string]any{"old": "Presidential Election 2024", "new": "Renamed"},
+	}, event["changes"])
+}
+
+func TestCandidateManagement(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "auditor1", "auditor")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	var election chaincode.Election
+	ledger.getJSON(t, "election_election1", &election)
+	election.Status = "scheduled"
+	ledger.putJSON(t, "election_election1", election)
+
+	voting := chaincode.VotingContract{}
+	err := voting.AddCandidate(commission, "election1", `{"candidate_id":"candidate3","name":"Carol"}`)
+	require.NoError(t, err)
+
+	err = voting.WithdrawCandidate(commission, "election1", "candidate2")
+	require.NoError(t, err)
+
+	err = voting.AddCandidate(commission, "election1", `{"candidate_id":"candidate2","name":"Impostor"}`)
+	require.EqualError(t, err, "candidate ID candidate2 is already used in election election1")
+
+	err = voting.ReorderCandidates(commission, "election1", `["candidate3","candidate1"]`)
+	require.EqualError(t, err, "expected 3 candidate IDs, got 2")
+
+	err = voting.ReorderCandidates(commission, "election1", `["candidate3","candidate2","candidate1"]`)
+	require.NoError(t, err)
+
+	ledger.getJSON(t, "election_election1", &election)
+	require.Equal(t, "candidate3", election.Candidates[0].CandidateID)
+	require.True(t, election.Candidates[1].Withdrawn)
+
+	election.Status = "live"
+	ledger.putJSON(t, "election_election1", election)
+
+	err = voting.WithdrawCandidate(commission, "election1", "candidate1")
+	require.EqualError(t, err, "WithdrawCandidate is not allowed while election election1 is live")
+
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate2")
+	require.EqualError(t, err, "candidate candidate2 has withdrawn from election election1")
+
+	tally, err := voting.PreviewTally(ledger.contextFor("Org1MSP", "auditor1"), "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 0, "candidate3": 0}, tally.Tallies)
+}
+
+func TestCastVoteCannotOverwriteExistingVote(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "voter2", "voter")
+
+	receipt, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+	require.Equal(t, "tx1", receipt.VoteID)
+
+	// A second ballot colliding on the same vote ID must not replace the first
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter2"), "election1", "candidate2")
+	require.EqualError(t, err, "vote tx1 already exists")
+
+	var vote chaincode.Vote
+	ledger.getJSON(t, "vote_tx1", &vote)
+	require.Equal(t, receipt.Receipt, vote.Receipt)
+
+	var privateBallot chaincode.PrivateBallot
+	require.NoError(t, json.Unmarshal(ledger.privateBallot(t, "election1", "tx1"), &privateBallot))
+	require.Equal(t, "candidate1", privateBallot.CandidateID)
+}
+
+func TestCastVoteSeparatesParticipationFromBallot(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "auditor1", "auditor")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+
+	voting := chaincode.VotingContract{}
+	_, err := ledger.castVote(voter, "election1", "candidate2")
+	require.NoError(t, err)
+
+	require.NotContains(t, string(ledger.state["vote_tx1"]), "voter1")
+	require.NotContains(t, ledger.lastEvent(t, "vote_cast"), "voter_id")
+
+	participation, err := voting.GetParticipation(voter, "election1", "voter1")
+	require.NoError(t, err)
+	require.Equal(t, "election1", participation.ElectionID)
+
+	// The double-vote check relies on the participation record, not the user's history
+	var user chaincode.User
+	ledger.getJSON(t, "user_Org1MSP/voter1", &user)
+	user.VotedElectionIds = []string{}
+	ledger.putJSON(t, "user_Org1MSP/voter1", user)
+	ledger.stub.GetTxIDReturns("tx2")
+	_, err = ledger.castVote(voter, "election1", "candidate1")
+	require.EqualError(t, err, "user has already voted in this election")
+
+	setElectionStatus(t, ledger, "election1", "ended")
+	tally, err := voting.ComputeVoteTally(ledger.contextFor("Org1MSP", "auditor1"), "tally1", "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 0, "candidate2": 1}, tally.Tallies)
+
+	// A ballot without a matching participation record is rejected by the tally
+	ledger.private["ballotCollection"][ballotKey(t, "election1", "tx3")] = []byte(`{"vote_id":"tx3","election_id":"election1","candidate_id":"candidate1","governorate":"Cairo"}`)
+	_, err = voting.ComputeVoteTally(ledger.contextFor("Org1MSP", "auditor1"), "tally2", "election1")
+	require.EqualError(t, err, "election election1 has 2 ballots but 1 participation records")
+}
+
+func TestCastVoteStoresBallotInPrivateCollection(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+
+	voting := chaincode.VotingContract{}
+	ledger.stub.GetTransientReturns(map[string][]byte{}, nil)
+	_, err := voting.CastVote(voter, "election1")
+	require.EqualError(t, err, "ballot must be passed in the transient map")
+
+	ledger.stub.GetTransientReturns(map[string][]byte{"ballot": []byte(`{"candidate_id":"candidate2","nonce":"voter-secret-nonce"}`)}, nil)
+	_, err = voting.CastVote(voter, "election1")
+	require.EqualError(t, err, "salt must be at least 32 characters")
+
+	_, err = ledger.castVote(voter, "election1", "candidate2")
+	require.NoError(t, err)
+	require.NotContains(t, string(ledger.state["vote_tx1"]), "candidate2")
+	require.NotContains(t, string(ledger.state["vote_tx1"]), "hash")
+
+	// The salt keeps the hash Fabric puts in the block from being matched to a candidate
+	require.Len(t, ledger.private["ballotCollection"], 1, "the ballot is stored once")
+	var privateBallot chaincode.PrivateBallot
+	require.NoError(t, json.Unmarshal(ledger.privateBallot(t, "election1", "tx1"), &privateBallot))
+	require.Equal(t, chaincode.PrivateBallot{
+		VoteID:      "tx1",
+		ElectionID:  "election1",
+		CandidateID: "candidate2",
+		Governorate: "Cairo",
+		Salt:        testSalt,
+	}, privateBallot)
+	for key, value := range ledger.state {
+		require.NotContains(t, string(value), testSalt, "salt leaked to public key %s", key)
+	}
+}
+
+func TestVerifyReceipt(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+
+	voting := chaincode.VotingContract{}
+	ledger.stub.GetTransientReturns(map[string][]byte{"ballot": []byte(`{"candidate_id":"candidate1","nonce":"short"}`)}, nil)
+	_, err := voting.CastVote(voter, "election1")
+	require.EqualError(t, err, "nonce must be at least 16 characters")
+
+	receipt, err := ledger.castVote(voter, "election1", "candidate1")
+	require.NoError(t, err)
+
+	// The receipt depends only on the voter's nonce and the vote ID, never the candidate
+	expected := sha256.Sum256([]byte(testNonce + receipt.VoteID))
+	require.Equal(t, hex.EncodeToString(expected[:]), receipt.Receipt)
+
+	verification, err := voting.VerifyReceipt(voter, "election1", receipt.Receipt)
+	require.NoError(t, err)
+	require.True(t, verification.Recorded)
+	require.True(t, verification.Counted)
+
+	verification, err = voting.VerifyReceipt(voter, "election1", "unknown")
+	require.NoError(t, err)
+	require.False(t, verification.Recorded)
+	require.False(t, verification.Counted)
+}
+
+// verifyInclusionProof recomputes the Merkle root the way an independent observer would
+func verifyInclusionProof(proof *chaincode.InclusionProof) string {
+	node := sha256.Sum256(append([]byte{0x00}, proof.Receipt...))
+	hash := node[:]
+	for _, step := range proof.Path {
+		sibling, _ := hex.DecodeString(step.Hash)
+		joined := []byte{0x01}
+		if step.Position == "left" {
+			joined = append(append(joined, sibling...), hash...)
+		} else {
+			joined = append(append(joined, hash...), sibling...)
+		}
+		node = sha256.Sum256(joined)
+		hash = node[:]
+	}
+	return hex.EncodeToString(hash)
+}
+
+func TestVerifyBallotHashes(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	voting := chaincode.VotingContract{}
+	for i, voterID := range []string{"voter1", "voter2", "voter3"} {
+		seedUser(t, ledger, voterID, "voter")
+		ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i+1))
+		_, err := ledger.castVote(ledger.contextFor("Org1MSP", voterID), "election1", "candidate1")
+		require.NoError(t, err)
+	}
+
+	verification, err := voting.VerifyBallotHashes(ledger.contextFor("Org1MSP", "voter1"), "election1")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.BallotHashVerification{
+		ElectionID: "election1",
+		Checked:    3,
+		Mismatched: []string{},
+		Missing:    []string{},
+		Valid:      true,
+	}, verification)
+
+	// A ballot altered on this peer no longer matches its ledger hash, a purged one is missing
+	ledgerHash, err := ledger.stub.GetPrivateDataHash("ballotCollection", ballotKey(t, "election1", "tx1"))
+	require.NoError(t, err)
+	ledger.stub.GetPrivateDataHashStub = func(collection string, key string) ([]byte, error) {
+		if key == ballotKey(t, "election1", "tx3") {
+			return nil, nil
+		}
+		if key == ballotKey(t, "election1", "tx1") {
+			return ledgerHash, nil
+		}
+		hash := sha256.Sum256(ledger.private[collection][key])
+		return hash[:], nil
+	}
+	ledger.private["ballotCollection"][ballotKey(t, "election1", "tx1")] = []byte(`{"vote_id":"tx1","candidate_id":"candidate2"}`)
+
+	verification, err = voting.VerifyBallotHashes(ledger.contextFor("Org1MSP", "voter1"), "election1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"tx1"}, verification.Mismatched)
+	require.Equal(t, []string{"tx3"}, verification.Missing)
+	require.False(t, verification.Valid)
+}
+
+func TestReceiptBoardInclusionProofs(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	var receipts []string
+	for i := 1; i <= 3; i++ {
+		voterID := fmt.Sprintf("voter%d", i)
+		seedUser(t, ledger, voterID, "voter")
+		ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i))
+		receipt, err := ledger.castVote(ledger.contextFor("Org1MSP", voterID), "election1", "candidate1")
+		require.NoError(t, err)
+		receipts = append(receipts, receipt.Receipt)
+	}
+
+	voting := chaincode.VotingContract{}
+	_, err := voting.GetReceiptInclusionProof(commission, "election1", receipts[0])
+	require.EqualError(t, err, "no receipt board has been committed for election election1")
+
+	err = voting.UpdateElectionStatus(commission, "election1", "ended")
+	require.NoError(t, err)
+
+	board, err := voting.GetReceiptBoard(commission, "election1")
+	require.NoError(t, err)
+	require.Equal(t, 3, board.LeafCount)
+	require.Equal(t, board.MerkleRoot, ledger.lastEvent(t, "election_status_changed")["merkle_root"])
+
+	for _, receipt := range receipts {
+		proof, err := voting.GetReceiptInclusionProof(commission, "election1", receipt)
+		require.NoError(t, err)
+		require.Equal(t, board.MerkleRoot, verifyInclusionProof(proof))
+	}
+
+	_, err = voting.GetReceiptInclusionProof(commission, "election1", "unknown")
+	require.EqualError(t, err, "receipt unknown is not on the board of election election1")
+}
+
+func TestComputeVoteTallySumsCounters(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "auditor1", "auditor")
+
+	for i, candidateID := range []string{"candidate1", "candidate2", "candidate2"} {
+		voterID := fmt.Sprintf("voter%d", i)
+		seedUser(t, ledger, voterID, "voter")
+		ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i))
+		_, err := ledger.castVote(ledger.contextFor("Org1MSP", voterID), "election1", candidateID)
+		require.NoError(t, err)
+	}
+
+	voting := chaincode.VotingContract{}
+	setElectionStatus(t, ledger, "election1", "ended")
+	ledger.stub.GetStateByRangeReturns(nil, fmt.Errorf("tally must not scan the world state"))
+	ledger.stub.GetStateByRangeStub = nil
+	tally, err := voting.ComputeVoteTally(ledger.contextFor("Org1MSP", "auditor1"), "tally1", "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 1, "candidate2": 2}, tally.Tallies)
+
+	// Fabric puts key hashes in the block, so no key may name the voter's choice
+	keys := slices.Collect(maps.Keys(ledger.state))
+	for _, collection := range ledger.private {
+		keys = slices.AppendSeq(keys, maps.Keys(collection))
+	}
+	for _, key := range keys {
+		require.NotContains(t, key, "candidate", "key %q names a candidate", key)
+	}
+}
+
+func TestVotesByElectionIndex(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "voter2", "voter")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "auditor1", "auditor")
+
+	_, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+	ledger.stub.GetTxIDReturns("tx2")
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter2"), "election1", "candidate2")
+	require.NoError(t, err)
+
+	// A vote stored before the index existed is only found after the migration
+	ledger.putJSON(t, "vote_legacy", chaincode.Vote{VoteID: "legacy", ElectionID: "election1"})
+
+	voting := chaincode.VotingContract{}
+	voter := ledger.contextFor("Org1MSP", "voter1")
+	count, err := voting.CountVotesByElection(voter, "election1")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	_, err = voting.MigrateVoteIndex(voter)
+	require.EqualError(t, err, "role voter is not allowed to call MigrateVoteIndex")
+
+	migrated, err := voting.MigrateVoteIndex(ledger.contextFor("Org1MSP", "commissioner"))
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	votes, err := voting.GetVotesByElection(ledger.contextFor("Org1MSP", "auditor1"), "election1")
+	require.NoError(t, err)
+	var voteIDs []string
+	for _, vote := range votes {
+		voteIDs = append(voteIDs, vote.VoteID)
+	}
+	require.Equal(t, []string{"legacy", "tx1", "tx2"}, voteIDs)
+
+	migrated, err = voting.MigrateVoteIndex(ledger.contextFor("Org1MSP", "commissioner"))
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+
+	_, err = voting.CountVotesByElection(voter, "missing")
+	require.EqualError(t, err, "the election missing does not exist")
+}
+
+func TestTallyHistory(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "auditor1", "auditor")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	auditor := ledger.contextFor("Org1MSP", "auditor1")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	voting := chaincode.VotingContract{}
+	setElectionStatus(t, ledger, "election1", "scheduled")
+	first, err := voting.ComputeVoteTally(auditor, "snapshot-b", "election1")
+	require.NoError(t, err)
+	require.Equal(t, 1, first.Version)
+
+	setElectionStatus(t, ledger, "election1", "live")
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+	_, err = voting.ComputeFinalTally(commission, "election1")
+	require.EqualError(t, err, "ComputeFinalTally is not allowed while election election1 is live")
+
+	setElectionStatus(t, ledger, "election1", "ended")
+	second, err := voting.ComputeVoteTally(auditor, "snapshot-a", "election1")
+	require.NoError(t, err)
+	require.Equal(t, 2, second.Version)
+
+	// Stored tallies are never overwritten
+	_, err = voting.ComputeVoteTally(auditor, "snapshot-b", "election1")
+	require.EqualError(t, err, "tally snapshot-b already exists for election election1")
+
+	stored, err := voting.GetTally(auditor, "election1", "snapshot-b")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 0, "candidate2": 0}, stored.Tallies)
+
+	latest, err := voting.GetLatestTally(auditor, "election1")
+	require.NoError(t, err)
+	require.Equal(t, "snapshot-a", latest.ID)
+
+	_, err = voting.ComputeVoteTally(auditor, "final", "election1")
+	require.EqualError(t, err, "tally ID final is reserved for the final tally")
+
+	final, err := voting.ComputeFinalTally(commission, "election1")
+	require.NoError(t, err)
+	require.True(t, final.IsFinal)
+	require.Equal(t, 3, final.Version)
+	require.Equal(t, map[string]int{"candidate1": 1, "candidate2": 0}, final.Tallies)
+
+	_, err = voting.ComputeFinalTally(commission, "election1")
+	require.EqualError(t, err, "failed to compute final tally: tally final already exists for election election1")
+
+	history, err := voting.GetTallyHistory(commission, "election1")
+	require.NoError(t, err)
+	var tallyIDs []string
+	for _, tally := range history {
+		tallyIDs = append(tallyIDs, tally.ID)
+	}
+	require.Equal(t, []string{"snapshot-b", "snapshot-a", "final"}, tallyIDs)
+
+	// Snapshots stored after the final tally do not replace it as the latest
+	_, err = voting.ComputeVoteTally(auditor, "snapshot-c", "election1")
+	require.NoError(t, err)
+	latest, err = voting.GetLatestTally(auditor, "election1")
+	require.NoError(t, err)
+	require.Equal(t, "final", latest.ID)
+}
+
+func TestPreviewTallyDoesNotWriteState(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "auditor1", "auditor")
+	_, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate2")
+	require.NoError(t, err)
+
+	writes := ledger.stub.PutStateCallCount()
+	events := ledger.stub.SetEventCallCount()
+
+	voting := chaincode.VotingContract{}
+	tally, err := voting.PreviewTally(ledger.contextFor("Org1MSP", "auditor1"), "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 0, "candidate2": 1}, tally.Tallies)
+	require.False(t, tally.IsFinal)
+
+	require.Equal(t, writes, ledger.stub.PutStateCallCount())
+	require.Equal(t, events, ledger.stub.SetEventCallCount())
+}
+
+func TestResultsEmbargo(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "auditor1", "auditor")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+	auditor := ledger.contextFor("Org1MSP", "auditor1")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+
+	_, err := ledger.castVote(voter, "election1", "candidate1")
+	require.NoError(t, err)
+
+	voting := chaincode.VotingContract{}
+	setStatus := func(status string) {
+		var election chaincode.Election
+		ledger.getJSON(t, "election_election1", &election)
+		election.Status = status
+		ledger.putJSON(t, "election_election1", election)
+	}
+
+	// While live only auditors see results
+	_, err = voting.PreviewTally(voter, "election1")
+	require.EqualError(t, err, "results of election election1 are embargoed while it is live: role voter cannot view them before publication")
+	_, err = voting.ComputeVoteTally(commission, "tally1", "election1")
+	var embargoed *chaincode.ResultsEmbargoedError
+	require.ErrorAs(t, err, &embargoed)
+	_, err = voting.GetVote(voter, "tx1")
+	require.ErrorAs(t, err, &embargoed)
+	votes, err := voting.GetAllVotes(voter)
+	require.NoError(t, err)
+	require.Empty(t, votes)
+
+	_, err = voting.ComputeVoteTally(auditor, "tally1", "election1")
+	require.EqualError(t, err, "election election1 is live, its counts can only be previewed until voting ends")
+	votes, err = voting.GetAllVotes(auditor)
+	require.NoError(t, err)
+	require.Len(t, votes, 1)
+
+	// Once ended the commission sees them too, the public still does not
+	setStatus("ended")
+	_, err = voting.ComputeVoteTally(commission, "tally1", "election1")
+	require.NoError(t, err)
+	_, err = voting.GetTally(commission, "election1", "tally1")
+	require.NoError(t, err)
+	_, err = voting.GetLatestTally(voter, "election1")
+	require.ErrorAs(t, err, &embargoed)
+
+	// Published results are public
+	setStatus("published")
+	tally, err := voting.GetLatestTally(voter, "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 1, "candidate2": 0}, tally.Tallies)
+	_, err = voting.GetVote(voter, "tx1")
+	require.NoError(t, err)
+
+	// Seeing published results does not let anyone store tallies
+	_, err = voting.ComputeVoteTally(voter, "tally2", "election1")
+	require.EqualError(t, err, "role voter is not allowed to call ComputeVoteTally")
+	_, err = voting.ComputeVoteTally(ledger.contextFor("Org1MSP", "stranger"), "tally2", "election1")
+	require.EqualError(t, err, "caller Org1MSP/stranger does not exist")
+}
+
+func TestTallyByGovernorate(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "auditor1", "auditor")
+
+	var election chaincode.Election
+	ledger.getJSON(t, "election_election1", &election)
+	election.EligibleGovernorates = []string{"Cairo", "Giza", "Alexandria"}
+	ledger.putJSON(t, "election_election1", election)
+
+	votes := []struct{ voterID, governorate, candidateID string }{
+		{"voter1", "Cairo", "candidate1"},
+		{"voter2", "Giza", "candidate2"},
+		{"voter3", "Giza", "candidate2"},
+	}
+	for i, v := range votes {
+		seedUser(t, ledger, v.voterID, "voter")
+		var user chaincode.User
+		ledger.getJSON(t, "user_Org1MSP/"+v.voterID, &user)
+		user.Governorate = v.governorate
+		ledger.putJSON(t, "user_Org1MSP/"+v.voterID, user)
+
+		ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i))
+		_, err := ledger.castVote(ledger.contextFor("Org1MSP", v.voterID), "election1", v.candidateID)
+		require.NoError(t, err)
+	}
+
+	// Moving after voting does not move the ballot
+	var user chaincode.User
+	ledger.getJSON(t, "user_Org1MSP/voter1", &user)
+	user.Governorate = "Giza"
+	ledger.putJSON(t, "user_Org1MSP/voter1", user)
+
+	auditor := ledger.contextFor("Org1MSP", "auditor1")
+	voting := chaincode.VotingContract{}
+	tally, err := voting.PreviewTally(auditor, "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 1, "candidate2": 2}, tally.Tallies)
+	require.Equal(t, map[string]map[string]int{
+		"Cairo":      {"candidate1": 1, "candidate2": 0},
+		"Giza":       {"candidate1": 0, "candidate2": 2},
+		"Alexandria": {"candidate1": 0, "candidate2": 0},
+	}, tally.ByGovernorate)
+
+	giza, err := voting.GetTallyByGovernorate(auditor, "election1", "Giza")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 0, "candidate2": 2}, giza)
+
+	_, err = voting.GetTallyByGovernorate(auditor, "election1", "Aswan")
+	require.EqualError(t, err, "governorate Aswan is not eligible in election election1")
+}
+
+func TestGetTurnout(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+
+	var election chaincode.Election
+	ledger.getJSON(t, "election_election1", &election)
+	election.EligibleGovernorates = []string{"Cairo", "Giza"}
+	ledger.putJSON(t, "election_election1", election)
+
+	for _, voterID := range []string{"voter1", "voter2", "voter3", "voter4"} {
+		seedUser(t, ledger, voterID, "voter")
+	}
+	var user chaincode.User
+	ledger.getJSON(t, "user_Org1MSP/voter4", &user)
+	user.Governorate = "Giza"
+	ledger.putJSON(t, "user_Org1MSP/voter4", user)
+
+	// Seeded users predate the voter index
+	voting := chaincode.VotingContract{}
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+	_, err := voting.MigrateVoterIndex(ledger.contextFor("Org1MSP", "voter1"))
+	require.EqualError(t, err, "role voter is not allowed to call MigrateVoterIndex")
+	migrated, err := voting.MigrateVoterIndex(commission)
+	require.NoError(t, err)
+	require.Equal(t, 4, migrated)
+	migrated, err = voting.MigrateVoterIndex(commission)
+	require.NoError(t, err)
+	require.Zero(t, migrated)
+
+	castAt := map[string]time.Time{
+		"voter1": testTxTime,
+		"voter2": testTxTime.Add(20 * time.Minute),
+		"voter4": testTxTime.Add(2 * time.Hour),
+	}
+	for i, voterID := range []string{"voter1", "voter2", "voter4"} {
+		ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i))
+		ledger.stub.GetTxTimestampReturns(timestamppb.New(castAt[voterID]), nil)
+		_, err := ledger.castVote(ledger.contextFor("Org1MSP", voterID), "election1", "candidate1")
+		require.NoError(t, err)
+	}
+
+	// The public turnout counters do not carry the vote ID next to the governorate
+	for key := range ledger.state {
+		if strings.HasPrefix(key, "\x00turnout\x00") {
+			require.NotContains(t, key, "tx")
+		}
+	}
+
+	// A voter who becomes an auditor no longer counts as registered
+	seedUser(t, ledger, "voter5", "voter")
+	_, err = voting.MigrateVoterIndex(commission)
+	require.NoError(t, err)
+	seedUser(t, ledger, "admin1", "admin")
+	err = voting.SetUserRole(ledger.contextFor("Org1MSP", "admin1"), "voter5", "auditor")
+	require.NoError(t, err)
+
+	turnout, err := voting.GetTurnout(commission, "election1")
+	require.NoError(t, err)
+	require.Equal(t, 3, turnout.Votes)
+	require.Equal(t, 4, turnout.RegisteredVoters)
+	require.Equal(t, 0.75, turnout.Rate)
+	require.Equal(t, map[string]chaincode.GovernorateTurnout{
+		"Cairo": {Votes: 2, RegisteredVoters: 3, Rate: 2.0 / 3.0},
+		"Giza":  {Votes: 1, RegisteredVoters: 1, Rate: 1},
+	}, turnout.ByGovernorate)
+	require.Equal(t, []chaincode.TurnoutBucket{
+		{Hour: "2024-01-15T10:00:00Z", Votes: 2},
+		{Hour: "2024-01-15T12:00:00Z", Votes: 1},
+	}, turnout.Hourly)
+}
+
+// certifyFinalTally has the first signer propose the final tally of an ended election
+// and every signer certify it
+func certifyFinalTally(t *testing.T, ledger *testLedger, electionID string, signers ...string) {
+	voting := chaincode.VotingContract{}
+	commission := ledger.contextFor("Org1MSP", signers[0])
+	_, err := voting.ComputeFinalTally(commission, electionID)
+	require.NoError(t, err)
+
+	status, err := voting.GetCertificationStatus(commission, electionID)
+	require.NoError(t, err)
+	for _, signerID := range signers {
+		err = voting.CertifyTally(ledger.contextFor("Org1MSP", signerID), electionID, status.TallyHash)
+		require.NoError(t, err)
+	}
+}
+
+func TestCertifyTally(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "commissioner2", "election_commission")
+	seedUser(t, ledger, "commissioner3", "election_commission")
+	seedUser(t, ledger, "auditor1", "auditor")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+	auditor := ledger.contextFor("Org1MSP", "auditor1")
+
+	_, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+
+	voting := chaincode.VotingContract{}
+	setElectionStatus(t, ledger, "election1", "scheduled")
+	err = voting.SetCertificationPolicy(commission, "election1", 2, `["commissioner","voter1"]`)
+	require.EqualError(t, err, "signer Org1MSP/voter1 must be election commission or auditor, not voter")
+	err = voting.SetCertificationPolicy(commission, "election1", 3, `["commissioner","auditor1"]`)
+	require.EqualError(t, err, "required certifications must be between 2 and 2, got 3")
+	err = voting.SetCertificationPolicy(auditor, "election1", 2, `["commissioner","auditor1"]`)
+	require.EqualError(t, err, "role auditor is not allowed to call SetCertificationPolicy")
+
+	// No identity can certify alone
+	err = voting.SetCertificationPolicy(commission, "election1", 1, `["commissioner"]`)
+	require.EqualError(t, err, "certification policy needs at least 2 signers")
+	err = voting.SetCertificationPolicy(commission, "election1", 1, `["commissioner","auditor1"]`)
+	require.EqualError(t, err, "required certifications must be between 2 and 2, got 1")
+
+	err = voting.SetCertificationPolicy(commission, "election1", 2, `["commissioner","commissioner2","auditor1"]`)
+	require.NoError(t, err)
+
+	err = voting.UpdateElectionStatus(commission, "election1", "live")
+	require.NoError(t, err)
+	err = voting.SetCertificationPolicy(commission, "election1", 2, `["commissioner","commissioner2"]`)
+	require.EqualError(t, err, "SetCertificationPolicy is not allowed while election election1 is live")
+	err = voting.UpdateElectionStatus(commission, "election1", "ended")
+	require.NoError(t, err)
+
+	_, err = voting.ComputeFinalTally(auditor, "election1")
+	require.EqualError(t, err, "role auditor is not allowed to call ComputeFinalTally")
+	err = voting.CertifyTally(auditor, "election1", "")
+	require.EqualError(t, err, "the final tally of election election1 has not been proposed")
+
+	_, err = voting.ComputeFinalTally(commission, "election1")
+	require.NoError(t, err)
+	status, err := voting.GetCertificationStatus(auditor, "election1")
+	require.NoError(t, err)
+	require.NotEmpty(t, status.TallyHash)
+	require.False(t, status.Certified)
+
+	err = voting.CertifyTally(auditor, "election1", "deadbeef")
+	require.EqualError(t, err, "tally hash deadbeef does not match the final tally of election election1")
+	err = voting.CertifyTally(ledger.contextFor("Org1MSP", "voter1"), "election1", status.TallyHash)
+	require.EqualError(t, err, "role voter is not allowed to call CertifyTally")
+	err = voting.CertifyTally(ledger.contextFor("Org1MSP", "commissioner3"), "election1", status.TallyHash)
+	require.EqualError(t, err, "Org1MSP/commissioner3 is not a signer of the certification policy of election election1")
+
+	err = voting.CertifyTally(auditor, "election1", status.TallyHash)
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP/auditor1", ledger.lastEvent(t, "tally_certified")["signer_id"])
+	err = voting.CertifyTally(auditor, "election1", status.TallyHash)
+	require.EqualError(t, err, "Org1MSP/auditor1 has already certified election election1")
+
+	// The quorum is not reached yet
+	err = voting.UpdateElectionStatus(commission, "election1", "published")
+	require.EqualError(t, err, "election election1 needs 2 certifications to be published, has 1")
+
+	err = voting.CertifyTally(commission, "election1", status.TallyHash)
+	require.NoError(t, err)
+	event := ledger.lastEvent(t, "results_certified")
+	require.Equal(t, []any{"Org1MSP/auditor1", "Org1MSP/commissioner"}, event["signers"])
+
+	err = voting.UpdateElectionStatus(commission, "election1", "published")
+	require.NoError(t, err)
+}
+
+func TestRecountElection(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "auditor1", "auditor")
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "voter2", "voter")
+	auditor := ledger.contextFor("Org1MSP", "auditor1")
+
+	voting := chaincode.VotingContract{}
+	_, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+	_, err = voting.RecountElection(auditor, "election1")
+	require.EqualError(t, err, "election election1 is live, its counts can only be previewed until voting ends")
+
+	setElectionStatus(t, ledger, "election1", "ended")
+	_, err = voting.RecountElection(auditor, "election1")
+	require.EqualError(t, err, "no tally has been computed for election election1")
+	_, err = voting.ComputeVoteTally(auditor, "tally1", "election1")
+	require.NoError(t, err)
+
+	_, err = voting.RecountElection(ledger.contextFor("Org1MSP", "voter1"), "election1")
+	require.EqualError(t, err, "role voter is not allowed to call RecountElection")
+
+	ledger.stub.GetTxIDReturns("recount1")
+	events := ledger.stub.SetEventCallCount()
+	recount, err := voting.RecountElection(auditor, "election1")
+	require.NoError(t, err)
+	require.Equal(t, "tally1", recount.ComparedTallyID)
+	require.Equal(t, 1, recount.BallotCount)
+	require.Empty(t, recount.Discrepancies)
+	require.Equal(t, events, ledger.stub.SetEventCallCount())
+
+	// A ballot cast after the stored tally shows up as a discrepancy
+	setElectionStatus(t, ledger, "election1", "live")
+	ledger.stub.GetTxIDReturns("tx2")
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter2"), "election1", "candidate2")
+	require.NoError(t, err)
+	setElectionStatus(t, ledger, "election1", "ended")
+
+	ledger.stub.GetTxIDReturns("recount2")
+	recount, err = voting.RecountElection(auditor, "election1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]chaincode.CountMismatch{"candidate2": {Recounted: 1, Stored: 0}}, recount.Discrepancies)
+	event := ledger.lastEvent(t, "recount_discrepancy")
+	require.Equal(t, "recount2", event["recount_id"])
+
+	// The recount is stored beside the tally, which is left as it was
+	var stored chaincode.Recount
+	recountKey, err := shim.CreateCompositeKey("recount", []string{"election1", "recount2"})
+	require.NoError(t, err)
+	ledger.getJSON(t, recountKey, &stored)
+	require.Equal(t, recount, &stored)
+	tally, err := voting.GetTally(auditor, "election1", "tally1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"candidate1": 1, "candidate2": 0}, tally.Tallies)
+}
+
+func TestCreateElectionCommitsLotsSeed(t *testing.T) {
+	ledger := newTestLedger()
+	seedUser(t, ledger, "commissioner", "election_commission")
+	ledger.stub.GetTxIDReturns("create-tx")
+
+	voting := chaincode.VotingContract{}
+	err := voting.CreateElection(ledger.contextFor("Org1MSP", "commissioner"), `{
+		"election_id": "election2", "name": "Referendum", "tie_break_rule": "lots",
+		"candidates": [{"candidate_id": "yes"}, {"candidate_id": "no"}],
+		"start_time": "2024-02-01T00:00:00Z", "end_time": "2024-02-02T00:00:00Z",
+		"lots_seed": "chosen-by-the-caller"
+	}`)
+	require.NoError(t, err)
+
+	var election chaincode.Election
+	ledger.getJSON(t, "election_election2", &election)
+	seed := sha256.Sum256([]byte("create-tx"))
+	require.Equal(t, hex.EncodeToString(seed[:]), election.LotsSeed)
+}
+
+func TestFinalTallyWinner(t *testing.T) {
+	// finalResultWithSeed casts one vote per candidate listed, ends the election and
+	// proposes the final tally
+	finalResultWithSeed := func(t *testing.T, rule string, lotsSeed string, candidateIDs ...string) (*testLedger, *chaincode.ElectionResult) {
+		ledger := newTestLedger()
+		seedLiveElection(t, ledger)
+		seedUser(t, ledger, "commissioner", "election_commission")
+
+		var election chaincode.Election
+		ledger.getJSON(t, "election_election1", &election)
+		election.TieBreakRule = rule
+		election.LotsSeed = lotsSeed
+		ledger.putJSON(t, "election_election1", election)
+
+		for i, candidateID := range candidateIDs {
+			voterID := fmt.Sprintf("voter%d", i)
+			seedUser(t, ledger, voterID, "voter")
+			ledger.stub.GetTxIDReturns(fmt.Sprintf("tx%d", i))
+			_, err := ledger.castVote(ledger.contextFor("Org1MSP", voterID), "election1", candidateID)
+			require.NoError(t, err)
+		}
+
+		voting := chaincode.VotingContract{}
+		commission := ledger.contextFor("Org1MSP", "commissioner")
+		require.NoError(t, voting.UpdateElectionStatus(commission, "election1", "ended"))
+		ledger.stub.GetTxIDReturns("final-tx")
+		tally, err := voting.ComputeFinalTally(commission, "election1")
+		require.NoError(t, err)
+		return ledger, tally.Result
+	}
+	finalResult := func(t *testing.T, rule string, candidateIDs ...string) (*testLedger, *chaincode.ElectionResult) {
+		return finalResultWithSeed(t, rule, "committed-seed", candidateIDs...)
+	}
+
+	t.Run("winner", func(t *testing.T) {
+		_, result := finalResult(t, "lots", "candidate2", "candidate2", "candidate1")
+		require.Equal(t, []string{"candidate2"}, result.Winners)
+		require.Equal(t, "winner", result.Outcome)
+		require.Equal(t, []chaincode.RankedCandidate{
+			{CandidateID: "candidate2", Votes: 2, Rank: 1},
+			{CandidateID: "candidate1", Votes: 1, Rank: 2},
+		}, result.Ranking)
+	})
+
+	t.Run("runoff", func(t *testing.T) {
+		_, result := finalResult(t, "", "candidate1", "candidate2")
+		require.Equal(t, "runoff", result.TieBreakRule)
+		require.Equal(t, "runoff_required", result.Outcome)
+		require.Empty(t, result.Winners)
+		require.Equal(t, []string{"candidate1", "candidate2"}, result.Tied)
+	})
+
+	t.Run("lots", func(t *testing.T) {
+		_, result := finalResult(t, "lots", "candidate1", "candidate2")
+		// The seed does not depend on the tx proposing the final tally
+		seed := sha256.Sum256([]byte("committed-seed\x00election1\x00" + `{"candidate1":1,"candidate2":1}`))
+		require.Equal(t, hex.EncodeToString(seed[:]), result.Seed)
+		require.Equal(t, "lots_drawn", result.Outcome)
+		require.Len(t, result.Winners, 1)
+		require.Contains(t, result.Tied, result.Winners[0])
+	})
+
+	t.Run("lots without a committed seed", func(t *testing.T) {
+		_, result := finalResultWithSeed(t, "lots", "", "candidate1", "candidate2")
+		require.Equal(t, "awaiting_commission_decision", result.Outcome)
+		require.Empty(t, result.Seed)
+		require.Empty(t, result.Winners)
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		ledger, result := finalResult(t, "manual", "candidate1", "candidate2")
+		require.Equal(t, "awaiting_commission_decision", result.Outcome)
+
+		voting := chaincode.VotingContract{}
+		commission := ledger.contextFor("Org1MSP", "commissioner")
+		// A policy stored before quorums had a minimum
+		ledger.putJSON(t, "certification_policy_election1", chaincode.CertificationPolicy{
+			ElectionID: "election1",
+			Required:   1,
+			Signers:    []string{"Org1MSP/commissioner"},
+		})
+		status, err := voting.GetCertificationStatus(commission, "election1")
+		require.NoError(t, err)
+		err = voting.CertifyTally(commission, "election1", status.TallyHash)
+		require.EqualError(t, err, "the tie in election election1 awaits a commission decision")
+
+		err = voting.ResolveTie(commission, "election1", "candidate3", "older candidate")
+		require.EqualError(t, err, "candidate candidate3 is not tied for first place in election election1")
+		err = voting.ResolveTie(commission, "election1", "candidate2", "decided by the electoral law")
+		require.NoError(t, err)
+		err = voting.ResolveTie(commission, "election1", "candidate1", "changed our minds")
+		require.EqualError(t, err, "the tie in election election1 has already been resolved")
+
+		result, err = voting.GetElectionResult(commission, "election1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"candidate2"}, result.Winners)
+		require.Equal(t, "decided_by_commission", result.Outcome)
+		require.Contains(t, result.Reasoning, "decided by the electoral law")
+
+		require.NoError(t, voting.CertifyTally(commission, "election1", status.TallyHash))
+
+		// Its proposer alone cannot get it published
+		err = voting.UpdateElectionStatus(commission, "election1", "published")
+		require.EqualError(t, err, "the final tally of election election1 must be certified by someone other than its proposer Org1MSP/commissioner")
+	})
+}
+
+func TestRegisterUserAuthorization(t *testing.T) {
+	ledger := newTestLedger()
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "voter1", "voter")
+
+	voting := chaincode.VotingContract{}
+	newcomer := ledger.contextFor("Org1MSP", "newcomer")
+	err := voting.RegisterUser(newcomer, "newcomer", "Cairo", "auditor")
+	require.EqualError(t, err, "self-registration is limited to the voter role")
+	err = voting.RegisterUser(newcomer, "someone-else", "Cairo", "voter")
+	require.EqualError(t, err, "clients can only register themselves, as Org1MSP/newcomer")
+	err = voting.RegisterUser(newcomer, "newcomer", "Cairo", "voter")
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP/newcomer", ledger.lastEvent(t, "user_registered")["registered_by"])
+
+	err = voting.RegisterUser(ledger.contextFor("Org1MSP", "voter1"), "voter2", "Cairo", "voter")
+	require.EqualError(t, err, "role voter is not allowed to call RegisterUser")
+
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+	err = voting.RegisterUser(commission, "auditor1", "Cairo", "auditor")
+	require.NoError(t, err)
+	err = voting.RegisterUser(commission, "admin1", "Cairo", "admin")
+	require.EqualError(t, err, "only admins can register admins")
+}
+
+func TestInitAdmin(t *testing.T) {
+	ledger := newTestLedger()
+	voting := chaincode.VotingContract{}
+
+	err := voting.InitAdmin(ledger.contextFor("Org1MSP", "voter1"), "Cairo")
+	require.EqualError(t, err, "only an admin identity of Org1MSP can bootstrap the first admin")
+
+	// Admins of other member organizations are not trusted with the registry
+	org2Admin := ledger.contextFor("Org2MSP", "org2admin")
+	org2Admin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	err = voting.InitAdmin(org2Admin, "Cairo")
+	require.EqualError(t, err, "only an admin identity of Org1MSP can bootstrap the first admin")
+
+	// The first admin is the caller itself
+	mspAdmin := ledger.contextFor("Org1MSP", "org1admin")
+	mspAdmin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	err = voting.InitAdmin(mspAdmin, "Cairo")
+	require.NoError(t, err)
+
+	isAdmin, err := voting.IsUserAdmin(ledger.contextFor("Org1MSP", "org1admin"))
+	require.NoError(t, err)
+	require.True(t, isAdmin)
+	var bootstrap map[string]string
+	ledger.getJSON(t, "admin_bootstrap", &bootstrap)
+	require.Equal(t, "Org1MSP/org1admin", bootstrap["admin_id"])
+
+	err = voting.InitAdmin(mspAdmin, "Cairo")
+	require.EqualError(t, err, "the first admin has already been bootstrapped")
+
+	// The bootstrapped admin registers the other privileged users
+	err = voting.RegisterUser(ledger.contextFor("Org1MSP", "org1admin"), "admin", "Cairo", "admin")
+	require.NoError(t, err)
+}
+
+func TestUserIDsAreQualifiedByMSP(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	voting := chaincode.VotingContract{}
+
+	org1Voter := ledger.contextFor("Org1MSP", "voter1")
+	org2Voter := ledger.contextFor("Org2MSP", "voter1")
+	err := voting.RegisterUser(org1Voter, "voter1", "Cairo", "voter")
+	require.NoError(t, err)
+	err = voting.RegisterUser(org2Voter, "Org1MSP/voter1", "Cairo", "voter")
+	require.EqualError(t, err, "clients can only register themselves, as Org2MSP/voter1")
+	err = voting.RegisterUser(org2Voter, "voter1", "Cairo", "voter")
+	require.NoError(t, err)
+
+	// The same CN issued by two organizations votes as two different voters
+	_, err = ledger.castVote(org1Voter, "election1", "candidate1")
+	require.NoError(t, err)
+	ledger.stub.GetTxIDReturns("tx2")
+	_, err = ledger.castVote(org2Voter, "election1", "candidate2")
+	require.NoError(t, err)
+
+	user, err := voting.GetUser(org2Voter, "voter1")
+	require.NoError(t, err)
+	require.Equal(t, "Org2MSP/voter1", user.ID)
+	participation, err := voting.GetParticipation(org2Voter, "election1", "Org1MSP/voter1")
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP/voter1", participation.VoterID)
+}
+
+func TestMigrateLegacyUsers(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+	ledger.putJSON(t, "user_voter1", chaincode.User{
+		ID:               "voter1",
+		Governorate:      "Cairo",
+		VotedElectionIds: []string{"election1"},
+		Role:             "voter",
+		Status:           "active",
+	})
+	legacyKey, err := shim.CreateCompositeKey("participation", []string{"election1", "voter1"})
+	require.NoError(t, err)
+	ledger.putJSON(t, legacyKey, chaincode.Participation{VoterID: "voter1", ElectionID: "election1", CreatedAt: "2024-01-15T10:30:00Z"})
+
+	voting := chaincode.VotingContract{}
+	_, err = voting.MigrateLegacyUsers(ledger.contextFor("Org1MSP", "commissioner"))
+	require.EqualError(t, err, "only an admin identity of Org1MSP can migrate legacy users")
+
+	// An admin of another organization cannot claim the legacy users for it
+	org2Admin := ledger.contextFor("Org2MSP", "org2admin")
+	org2Admin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	_, err = voting.MigrateLegacyUsers(org2Admin)
+	require.EqualError(t, err, "only an admin identity of Org1MSP can migrate legacy users")
+	require.Contains(t, ledger.state, "user_voter1")
+	require.NotContains(t, ledger.state, "user_Org2MSP/voter1")
+
+	mspAdmin := ledger.contextFor("Org1MSP", "org1admin")
+	mspAdmin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	migrated, err := voting.MigrateLegacyUsers(mspAdmin)
+	require.NoError(t, err)
+	require.Equal(t, 1, migrated)
+
+	require.NotContains(t, ledger.state, "user_voter1")
+	require.NotContains(t, ledger.state, legacyKey)
+	var user chaincode.User
+	ledger.getJSON(t, "user_Org1MSP/voter1", &user)
+	require.Equal(t, "Org1MSP/voter1", user.ID)
+
+	// The migrated voter still cannot vote twice
+	_, err = ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.EqualError(t, err, "user has already voted in this election")
+
+	migrated, err = voting.MigrateLegacyUsers(mspAdmin)
+	require.NoError(t, err)
+	require.Equal(t, 0, migrated)
+}
+
+// withRoleAttribute gives the client of ctx a certificate carrying the role enrollment attribute
+func withRoleAttribute(ctx *mocks.TransactionContext, role string) *mocks.TransactionContext {
+	ctx.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueStub = func(name string) (string, bool, error) {
+		if name == "role" {
+			return role, true, nil
+		}
+		return "", false, nil
+	}
+	return ctx
+}
+
+func TestRolePolicy(t *testing.T) {
+	setup := func(t *testing.T, policy string) *testLedger {
+		ledger := newTestLedger()
+		seedUser(t, ledger, "admin", "admin")
+		seedUser(t, ledger, "voter1", "voter")
+		seedUser(t, ledger, "commissioner", "election_commission")
+		if policy != "" {
+			voting := chaincode.VotingContract{}
+			require.NoError(t, voting.SetRolePolicy(ledger.contextFor("Org1MSP", "admin"), policy))
+		}
+		return ledger
+	}
+	voting := chaincode.VotingContract{}
+
+	t.Run("defaults to the on-chain role", func(t *testing.T) {
+		ledger := setup(t, "")
+		policy, err := voting.GetRolePolicy(ledger.contextFor("Org1MSP", "voter1"))
+		require.NoError(t, err)
+		require.Equal(t, "onchain", policy.Policy)
+
+		voter := withRoleAttribute(ledger.contextFor("Org1MSP", "voter1"), "election_commission")
+		err = voting.RegisterUser(voter, "voter2", "Cairo", "voter")
+		require.EqualError(t, err, "role voter is not allowed to call RegisterUser")
+	})
+
+	t.Run("attribute", func(t *testing.T) {
+		ledger := setup(t, "attribute")
+		require.Equal(t, "Org1MSP/admin", ledger.lastEvent(t, "role_policy_set")["set_by"])
+
+		voter := withRoleAttribute(ledger.contextFor("Org1MSP", "voter1"), "election_commission")
+		err := voting.RegisterUser(voter, "voter2", "Cairo", "voter")
+		require.NoError(t, err)
+
+		err = voting.RegisterUser(ledger.contextFor("Org1MSP", "commissioner"), "voter3", "Cairo", "voter")
+		require.EqualError(t, err, "certificate of Org1MSP/commissioner has no role attribute issued by Org1MSP")
+	})
+
+	t.Run("both", func(t *testing.T) {
+		ledger := setup(t, "both")
+
+		commission := withRoleAttribute(ledger.contextFor("Org1MSP", "commissioner"), "election_commission")
+		err := voting.RegisterUser(commission, "voter2", "Cairo", "voter")
+		require.NoError(t, err)
+
+		voter := withRoleAttribute(ledger.contextFor("Org1MSP", "voter1"), "election_commission")
+		err = voting.RegisterUser(voter, "voter3", "Cairo", "voter")
+		require.EqualError(t, err, `certificate role "election_commission" of Org1MSP/voter1 does not match its on-chain role voter`)
+	})
+
+	t.Run("either", func(t *testing.T) {
+		ledger := setup(t, "either")
+
+		voter := withRoleAttribute(ledger.contextFor("Org1MSP", "voter1"), "auditor")
+		err := voting.UpdateUserStatus(voter, "commissioner", "suspended", "audit")
+		require.NoError(t, err)
+
+		err = voting.RegisterUser(voter, "voter2", "Cairo", "voter")
+		require.EqualError(t, err, "role voter+auditor is not allowed to call RegisterUser")
+
+		// Role attributes issued by other organizations are ignored
+		org2Voter := ledger.contextFor("Org2MSP", "voter1")
+		err = voting.RegisterUser(org2Voter, "voter1", "Cairo", "voter")
+		require.NoError(t, err)
+		org2Voter = withRoleAttribute(org2Voter, "election_commission")
+		err = voting.RegisterUser(org2Voter, "voter4", "Cairo", "voter")
+		require.EqualError(t, err, "role voter is not allowed to call RegisterUser")
+	})
+
+	t.Run("only admins change it", func(t *testing.T) {
+		ledger := setup(t, "")
+		err := voting.SetRolePolicy(ledger.contextFor("Org1MSP", "commissioner"), "attribute")
+		require.EqualError(t, err, "role election_commission is not allowed to call SetRolePolicy")
+		err = voting.SetRolePolicy(ledger.contextFor("Org1MSP", "admin"), "sometimes")
+		require.EqualError(t, err, "invalid role policy: sometimes. Must be one of: [onchain attribute both either]")
+
+		// An admin locked out by the attribute policy is recovered by an MSP admin
+		err = voting.SetRolePolicy(ledger.contextFor("Org1MSP", "admin"), "attribute")
+		require.NoError(t, err)
+		err = voting.SetRolePolicy(ledger.contextFor("Org1MSP", "admin"), "onchain")
+		require.EqualError(t, err, "certificate of Org1MSP/admin has no role attribute issued by Org1MSP")
+
+		// Admins of other organizations cannot
+		org2Admin := ledger.contextFor("Org2MSP", "org2admin")
+		org2Admin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+		err = voting.SetRolePolicy(org2Admin, "onchain")
+		require.EqualError(t, err, "caller Org2MSP/org2admin does not exist")
+
+		mspAdmin := ledger.contextFor("Org1MSP", "org1admin")
+		mspAdmin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+		err = voting.SetRolePolicy(mspAdmin, "onchain")
+		require.NoError(t, err)
+	})
+}
+
+func TestPermissionMatrix(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "admin", "admin")
+	seedUser(t, ledger, "voter1", "voter")
+	seedUser(t, ledger, "commissioner", "election_commission")
+	admin := ledger.contextFor("Org1MSP", "admin")
+	commission := ledger.contextFor("Org1MSP", "commissioner")
+	voter := ledger.contextFor("Org1MSP", "voter1")
+
+	voting := chaincode.VotingContract{}
+	permissions, err := voting.GetPermissions(voter)
+	require.NoError(t, err)
+	index := slices.IndexFunc(permissions, func(p *chaincode.Permission) bool { return p.Function == "CastVote" })
+	require.NotEqual(t, -1, index)
+	require.Equal(t, chaincode.Permission{Function: "CastVote", Roles: []string{}, Statuses: []string{"live"}}, *permissions[index])
+
+	err = voting.SetPermission(commission, "GetAllUsers", `["admin"]`, `[]`)
+	require.EqualError(t, err, "role election_commission is not allowed to call SetPermission")
+	err = voting.SetPermission(admin, "Unknown", `[]`, `[]`)
+	require.EqualError(t, err, "transaction Unknown has no permission entry")
+	err = voting.SetPermission(admin, "GetAllUsers", `["root"]`, `[]`)
+	require.EqualError(t, err, "invalid role: root. Must be one of: [voter election_commission auditor admin]")
+
+	// Governance restricts a transaction that is open by default
+	_, err = voting.GetAllUsers(voter)
+	require.NoError(t, err)
+	err = voting.SetPermission(admin, "GetAllUsers", `["admin","auditor"]`, `[]`)
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP/admin", ledger.lastEvent(t, "permission_set")["set_by"])
+	_, err = voting.GetAllUsers(voter)
+	require.EqualError(t, err, "role voter is not allowed to call GetAllUsers")
+	_, err = voting.GetAllUsers(admin)
+	require.NoError(t, err)
+
+	// Statuses narrow the elections a transaction is accepted for
+	err = voting.SetPermission(admin, "GetTurnout", `[]`, `["ended","published"]`)
+	require.NoError(t, err)
+	_, err = voting.GetTurnout(voter, "election1")
+	require.EqualError(t, err, "GetTurnout is not allowed while election election1 is live")
+
+	// Widening the matrix does not bypass the lifecycle checks of a transaction
+	err = voting.SetPermission(admin, "UpdateElection", `["election_commission"]`, `["scheduled","live"]`)
+	require.NoError(t, err)
+	err = voting.UpdateElection(commission, "election1", `{"name":"Renamed"}`)
+	require.EqualError(t, err, "election election1 is live and can no longer be edited")
+
+	// Restricted transactions cannot be opened to every client
+	err = voting.SetPermission(admin, "ClearElections", `[]`, `[]`)
+	require.EqualError(t, err, "transaction ClearElections is restricted by default and cannot be opened to every client")
+
+	// The entries governing access control are fixed
+	err = voting.SetPermission(admin, "SetPermission", `["auditor"]`, `[]`)
+	require.EqualError(t, err, "the permission entry of SetPermission is fixed")
+	err = voting.SetPermission(admin, "SetRolePolicy", `["election_commission"]`, `[]`)
+	require.EqualError(t, err, "the permission entry of SetRolePolicy is fixed")
+	ledger.putJSON(t, "permission_SetPermission", chaincode.Permission{Function: "SetPermission", Roles: []string{"voter"}})
+	err = voting.SetPermission(voter, "GetAllUsers", `[]`, `[]`)
+	require.EqualError(t, err, "role voter is not allowed to call SetPermission")
+
+	// A governance MSP admin can repair the matrix without being registered, an admin of
+	// another organization cannot
+	org2Admin := ledger.contextFor("Org2MSP", "org2admin")
+	org2Admin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	err = voting.SetPermission(org2Admin, "GetAllUsers", `[]`, `[]`)
+	require.EqualError(t, err, "caller Org2MSP/org2admin does not exist")
+	mspAdmin := ledger.contextFor("Org1MSP", "org1admin")
+	mspAdmin.GetClientIdentity().(*mocks.ClientIdentity).GetAttributeValueReturns("admin", true, nil)
+	err = voting.SetPermission(mspAdmin, "GetAllUsers", `[]`, `[]`)
+	require.NoError(t, err)
+	_, err = voting.GetAllUsers(voter)
+	require.NoError(t, err)
+	// Default entries, which carry no set_by or set_at, still match the contract metadata
+	response := ledger.invoke(t, "Org1MSP", "voter1", "GetPermissions")
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+}
+
+func TestTallyResponsesMatchContractSchema(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger) // Stored before lots seeds were committed
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "auditor1", "auditor")
+	seedUser(t, ledger, "voter1", "voter")
+	_, err := ledger.castVote(ledger.contextFor("Org1MSP", "voter1"), "election1", "candidate1")
+	require.NoError(t, err)
+
+	// Responses go through contractapi, which checks them against the contract metadata
+	ok := func(cn string, function string, args ...string) {
+		t.Helper()
+		response := ledger.invoke(t, "Org1MSP", cn, function, args...)
+		require.Equal(t, int32(shim.OK), response.Status, response.Message)
+	}
+	ok("voter1", "GetAllElections")
+	ok("auditor1", "PreviewTally", "election1")
+
+	setElectionStatus(t, ledger, "election1", "ended")
+	ok("commissioner", "ComputeVoteTally", "snapshot", "election1")
+	ok("commissioner", "GetTally", "election1", "snapshot")
+	ok("commissioner", "ComputeFinalTally", "election1")
+	ok("commissioner", "GetElectionResult", "election1")
+	ok("commissioner", "GetLatestTally", "election1")
+	ok("commissioner", "GetTallyHistory", "election1")
+}
+
+func TestTransactionHooks(t *testing.T) {
+	ledger := newTestLedger()
+	seedLiveElection(t, ledger)
+	seedUser(t, ledger, "commissioner", "election_commission")
+	seedUser(t, ledger, "commissioner2", "election_commission")
+	var user chaincode.User
+	ledger.getJSON(t, "user_Org1MSP/commissioner2", &user)
+	user.Status = "suspended"
+	ledger.putJSON(t, "user_Org1MSP/commissioner2", user)
+
+	response := ledger.invoke(t, "Org1MSP", "commissioner", "DropTables")
+	require.Equal(t, int32(shim.ERROR), response.Status)
+	require.Equal(t, "unknown transaction DropTables", response.Message)
+
+	response = ledger.invoke(t, "Org1MSP", "newcomer", "VotingContract:CastVote", "election1")
+	require.Equal(t, "CastVote requires a registered caller, Org1MSP/newcomer is not registered", response.Message)
+	response = ledger.invoke(t, "Org1MSP", "commissioner2", "UpdateElectionStatus", "election1", "ended")
+	require.Equal(t, "UpdateElectionStatus requires an active account, Org1MSP/commissioner2 is suspended", response.Message)
+	response = ledger.invoke(t, "Org1MSP", "commissioner2", "updateElectionStatus", "election1", "ended")
+	require.Equal(t, "UpdateElectionStatus requires an active account, Org1MSP/commissioner2 is suspended", response.Message)
+
+	// Transactions without requirements still deny suspended callers their role
+	response = ledger.invoke(t, "Org1MSP", "commissioner2", "RegisterUser", "voter9", "Cairo", "voter")
+	require.Equal(t, "account Org1MSP/commissioner2 is suspended", response.Message)
+	response = ledger.invoke(t, "Org1MSP", "commissioner", "CastVote", "election1")
+	require.Equal(t, `CastVote requires "ballot" in the transient map`, response.Message)
+
+	// The caller's user record is read once, however many checks the transaction makes
+	reads := ledger.stub.GetStateCallCount()
+	response = ledger.invoke(t, "Org1MSP", "commissioner", "UpdateElectionStatus", "election1", "ended")
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+	callerReads := 0
+	for i := reads; i < ledger.stub.GetStateCallCount(); i++ {
+		if ledger.stub.GetStateArgsForCall(i) == "user_Org1MSP/commissioner" {
+			callerReads++
+		}
+	}
+	require.Equal(t, 1, callerReads)
+	require.Equal(t, "Org1MSP/commissioner", ledger.lastEvent(t, "election_status_changed")["changed_by"])
+}
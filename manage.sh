@@ -10,9 +10,12 @@ function build() {
     ./network.sh up createChannel -ca -s couchdb
 
     # Deploy the chaincode
-    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go
+    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go -cccg ../../chaincode-go/collections_config.json
 
-    cd ../../application
+    cd ../../
+    bootstrap_admin
+
+    cd application
     ./mongo.sh start
     npm install
     npm run build
@@ -20,6 +23,47 @@ function build() {
     cd ../
 }
 
+# Bootstrap Admin@org1.example.com as the first chaincode admin, then have it register
+# the application's gateway identity (CN "admin") as an admin too. InitAdmin only
+# accepts Org1MSP admins and always registers the caller itself.
+function bootstrap_admin() {
+    cd hyperledger-fabric/network
+
+    export PATH=${PWD}/../bin:$PATH
+    export FABRIC_CFG_PATH=${PWD}/../config
+    . scripts/envVar.sh
+    parsePeerConnectionParameters 1 2
+    setGlobals 1
+
+    peer chaincode invoke -o localhost:7050 --ordererTLSHostnameOverride orderer.example.com \
+        --tls --cafile "$ORDERER_CA" -C mychannel -n basic "${PEER_CONN_PARMS[@]}" --waitForEvent \
+        -c '{"function":"InitAdmin","Args":["'"${ADMIN_GOVERNORATE:-Cairo}"'"]}'
+
+    peer chaincode invoke -o localhost:7050 --ordererTLSHostnameOverride orderer.example.com \
+        --tls --cafile "$ORDERER_CA" -C mychannel -n basic "${PEER_CONN_PARMS[@]}" \
+        -c '{"function":"RegisterUser","Args":["admin","'"${ADMIN_GOVERNORATE:-Cairo}"'","admin"]}'
+
+    cd ../../
+}
+
+# Move users registered before user IDs were MSP-qualified to Org1MSP/<CN>.
+# Run once after upgrading a network that already has users.
+function migrate_users() {
+    cd hyperledger-fabric/network
+
+    export PATH=${PWD}/../bin:$PATH
+    export FABRIC_CFG_PATH=${PWD}/../config
+    . scripts/envVar.sh
+    parsePeerConnectionParameters 1 2
+    setGlobals 1
+
+    peer chaincode invoke -o localhost:7050 --ordererTLSHostnameOverride orderer.example.com \
+        --tls --cafile "$ORDERER_CA" -C mychannel -n basic "${PEER_CONN_PARMS[@]}" \
+        -c '{"function":"MigrateLegacyUsers","Args":[]}'
+
+    cd ../../
+}
+
 function clean() {
     # Navigate to the hyperledger-fabric network directory
     cd hyperledger-fabric/network
@@ -44,7 +88,7 @@ function deploy() {
     cd hyperledger-fabric/network
 
     # Deploy the chaincode
-    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go
+    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go -cccg ../../chaincode-go/collections_config.json
 }
 
 function upgrade() {
@@ -52,7 +96,7 @@ function upgrade() {
     cd hyperledger-fabric/network
 
     # Upgrade the chaincode
-    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go -ccv 2.0
+    ./network.sh deployCC -ccn basic -ccp ../../chaincode-go -ccl go -cccg ../../chaincode-go/collections_config.json -ccv 2.0
 }
 
 function restart() {
@@ -79,11 +123,15 @@ elif [ "$1" == "clean" ]; then
     clean
 elif [ "$1" == "deploy" ]; then
     deploy
+elif [ "$1" == "bootstrap" ]; then
+    bootstrap_admin
+elif [ "$1" == "migrate-users" ]; then
+    migrate_users
 elif [ "$1" == "upgrade" ]; then
     upgrade
 elif [ "$1" == "restart" ]; then
     restart
 else
-    echo "Usage: $0 {build|clean|deploy|upgrade|restart}"
+    echo "Usage: $0 {build|clean|deploy|bootstrap|migrate-users|upgrade|restart}"
     exit 1
 fi